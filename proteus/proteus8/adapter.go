@@ -2,6 +2,14 @@ package main
 
 import "fmt"
 
+// The most bind parameters each database accepts in a single statement, for use with WithMaxParams.
+const (
+	MySQLMaxParams    = 65535
+	SqliteMaxParams   = 32766 // 999 before SQLite 3.32
+	PostgresMaxParams = 65535
+	OracleMaxParams   = 1000 // Oracle limits an IN list to 1000 expressions
+)
+
 func MySQL(pos int) string {
 	return "?"
 }
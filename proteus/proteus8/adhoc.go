@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Query runs query without a DAO, and returns the rows as T, mapped as they would be for a Querier func
+// that returns T. Each :name: in the query is bound to the value of that key in params; a slice value is
+// expanded as it would be for a func param, and calls are split at the dialect's MaxParams.
+// The options are the ones that can be passed to Build.
+//
+//	people, err := Query[[]Person](ctx, db, PostgresDialect, "SELECT * FROM PERSON WHERE age IN (:ages:)", map[string]interface{}{"ages": []int{20, 30}})
+//	count, err := Query[int](ctx, db, PostgresDialect, "SELECT COUNT(*) FROM PERSON", nil)
+//
+// The query is parsed once. Without options, the implementation built for each combination of dialect
+// and param types is reused too.
+func Query[T any](ctx context.Context, q Querier, dialect Dialect, query string, params map[string]interface{}, options ...Option) (T, error) {
+	var zero T
+	out, err := runAdHoc(ctx, q, qType, reflect.TypeOf(&zero).Elem(), dialect, query, params, options)
+	if err != nil {
+		return zero, err
+	}
+	return out.Interface().(T), nil
+}
+
+// Exec runs query without a DAO, binding its params as Query does.
+func Exec(ctx context.Context, e Executor, dialect Dialect, query string, params map[string]interface{}, options ...Option) (sql.Result, error) {
+	out, err := runAdHoc(ctx, e, exType, resultType, dialect, query, params, options)
+	if err != nil {
+		return nil, err
+	}
+	result, _ := out.Interface().(sql.Result)
+	return result, nil
+}
+
+// adHocNames holds the param names of each query, in the order they first appear.
+var adHocNames sync.Map
+
+// adHocImplementations holds the implementation for each query, func type, and dialect, when there are no options.
+var adHocImplementations sync.Map
+
+type adHocKey struct {
+	query     string
+	funcType  reflect.Type
+	pa        uintptr
+	maxParams int
+}
+
+var anyType = reflect.TypeOf((*interface{})(nil)).Elem()
+
+func runAdHoc(ctx context.Context, runner interface{}, runnerType reflect.Type, outType reflect.Type, dialect Dialect, query string, params map[string]interface{}, options []Option) (reflect.Value, error) {
+	if err := ctx.Err(); err != nil {
+		return reflect.Value{}, err
+	}
+	if runner == nil {
+		return reflect.Value{}, errors.New("need to supply an Executor or Querier")
+	}
+	names, err := adHocParamNames(query)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	in := []reflect.Type{runnerType}
+	args := []reflect.Value{reflect.New(runnerType).Elem()}
+	args[0].Set(reflect.ValueOf(runner))
+	nameOrderMap := map[string]int{}
+	for k, name := range names {
+		v, ok := params[name]
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("no value for param %s", name)
+		}
+		arg := reflect.Zero(anyType)
+		if v != nil {
+			arg = reflect.ValueOf(v)
+		}
+		in = append(in, arg.Type())
+		args = append(args, arg)
+		nameOrderMap[name] = k + 1
+	}
+	funcType := reflect.FuncOf(in, []reflect.Type{outType, errType}, false)
+
+	key := adHocKey{query: query, funcType: funcType, pa: reflect.ValueOf(dialect.Params).Pointer(), maxParams: dialect.MaxParams}
+	implementation, ok := adHocImplementations.Load(key)
+	if !ok || len(options) > 0 {
+		impl, err := makeImplementation(funcType, query, dialect.Params, nameOrderMap, makeBuildOptions(dialect.options(options)))
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		implementation = impl
+		if len(options) == 0 {
+			implementation, _ = adHocImplementations.LoadOrStore(key, impl)
+		}
+	}
+
+	out := implementation.(func([]reflect.Value) []reflect.Value)(args)
+	if err, _ := out[1].Interface().(error); err != nil {
+		return reflect.Value{}, err
+	}
+	return out[0], nil
+}
+
+// adHocParamNames returns the names of the params in query, in the order they first appear.
+func adHocParamNames(query string) ([]string, error) {
+	if names, ok := adHocNames.Load(query); ok {
+		return names.([]string), nil
+	}
+	tokens, err := lexQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	seen := map[string]bool{}
+	for _, tok := range tokens {
+		if tok.kind == paramToken && !seen[tok.value] {
+			seen[tok.value] = true
+			names = append(names, tok.value)
+		}
+	}
+	adHocNames.Store(query, names)
+	return names, nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	ctx := context.Background()
+	fw := &fakeWrapper{cols: []string{"id", "name", "age"}, rows: [][]interface{}{{int64(1), "fred", int64(20)}}}
+	people, err := Query[[]Person](ctx, fw, PostgresDialect, "SELECT * FROM PERSON WHERE name = :name: AND age IN (:ages:) OR name = :name:",
+		map[string]interface{}{"name": "fred", "ages": []int{20, 30}, "unused": 1})
+	if err != nil || !reflect.DeepEqual(people, []Person{{Id: 1, Name: "fred", Age: 20}}) {
+		t.Errorf("unexpected people %+v, %v", people, err)
+	}
+	person, err := Query[*Person](ctx, fw, PostgresDialect, "SELECT * FROM PERSON WHERE id = :id:", map[string]interface{}{"id": 1})
+	if err != nil || person == nil || person.Name != "fred" {
+		t.Errorf("unexpected person %+v, %v", person, err)
+	}
+	expectedQueries := []string{
+		"SELECT * FROM PERSON WHERE name = $1 AND age IN ($2, $3) OR name = $4",
+		"SELECT * FROM PERSON WHERE id = $1",
+	}
+	expectedArgs := [][]interface{}{{"fred", 20, 30, "fred"}, {1}}
+	if !reflect.DeepEqual(fw.queries, expectedQueries) {
+		t.Errorf("expected %q, got %q", expectedQueries, fw.queries)
+	}
+	if !reflect.DeepEqual(fw.args, expectedArgs) {
+		t.Errorf("expected %#v, got %#v", expectedArgs, fw.args)
+	}
+
+	if _, err := Query[*Person](ctx, fw, PostgresDialect, "SELECT * FROM PERSON WHERE id = :id:", nil); err == nil {
+		t.Error("expected an error for a missing param")
+	}
+	if _, err := Query[*Person](ctx, fw, PostgresDialect, "SELECT * FROM PERSON WHERE id = :id", nil); err == nil {
+		t.Error("expected an error for an unterminated param")
+	}
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := Query[*Person](canceled, fw, PostgresDialect, "SELECT * FROM PERSON", nil); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if len(fw.queries) != 2 {
+		t.Errorf("expected no more queries, got %q", fw.queries)
+	}
+	if _, err := Query[[]Person](ctx, nil, PostgresDialect, "SELECT * FROM PERSON", nil); err == nil {
+		t.Error("expected an error for a nil Querier")
+	}
+	if _, err := Query[map[int]Person](ctx, fw, PostgresDialect, "SELECT * FROM PERSON", nil); err == nil {
+		t.Error("expected an error for a map without a key column")
+	}
+	if _, err := Query[Person](ctx, fw, PostgresDialect, "SELECT * FROM PERSON", nil); err == nil {
+		t.Error("expected an error for a struct result")
+	}
+}
+
+func TestQueryScalar(t *testing.T) {
+	ctx := context.Background()
+	fw := &fakeWrapper{cols: []string{"count"}, rows: [][]interface{}{{int64(3)}}}
+	count, err := Query[int](ctx, fw, PostgresDialect, "SELECT COUNT(*) FROM PERSON WHERE age > :age:", map[string]interface{}{"age": 10})
+	if err != nil || count != 3 {
+		t.Errorf("expected 3, got %d, %v", count, err)
+	}
+	fw.rows = [][]interface{}{{[]byte("fred")}, {nil}, {"bob"}}
+	names, err := Query[[]string](ctx, fw, PostgresDialect, "SELECT name FROM PERSON", nil)
+	if err != nil || !reflect.DeepEqual(names, []string{"fred", "", "bob"}) {
+		t.Errorf("unexpected names %q, %v", names, err)
+	}
+	fw.rows = nil
+	name, err := Query[*string](ctx, fw, PostgresDialect, "SELECT name FROM PERSON WHERE id = :id:", map[string]interface{}{"id": 5})
+	if err != nil || name != nil {
+		t.Errorf("expected nil for no rows, got %v, %v", name, err)
+	}
+	if count, err := Query[int](ctx, fw, PostgresDialect, "SELECT COUNT(*) FROM PERSON", nil); err != nil || count != 0 {
+		t.Errorf("expected 0 for no rows, got %d, %v", count, err)
+	}
+	fw.cols = []string{"id", "name"}
+	fw.rows = [][]interface{}{{int64(1), "fred"}}
+	if _, err := Query[int](ctx, fw, PostgresDialect, "SELECT id, name FROM PERSON", nil); err == nil {
+		t.Error("expected an error for more than one column")
+	}
+}
+
+func TestQueryOptions(t *testing.T) {
+	fw := &fakeWrapper{cols: []string{"id", "name", "age"}}
+	query := "SELECT * FROM PERSON WHERE id IN (:ids:)"
+	params := map[string]interface{}{"ids": []int{1, 2, 3}}
+	if _, err := Query[[]Person](context.Background(), fw, PostgresDialect, query, params, WithMaxParams(2)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Query[[]Person](context.Background(), fw, PostgresDialect, query, params); err != nil {
+		t.Fatal(err)
+	}
+	small := Dialect{Params: Postgres, MaxParams: 2}
+	if _, err := Query[[]Person](context.Background(), fw, small, query, params); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"SELECT * FROM PERSON WHERE id IN ($1, $2)",
+		"SELECT * FROM PERSON WHERE id IN ($1)",
+		"SELECT * FROM PERSON WHERE id IN ($1, $2, $3)",
+		"SELECT * FROM PERSON WHERE id IN ($1, $2)",
+		"SELECT * FROM PERSON WHERE id IN ($1)",
+	}
+	if !reflect.DeepEqual(fw.queries, expected) {
+		t.Errorf("expected %q, got %q", expected, fw.queries)
+	}
+}
+
+func TestExec(t *testing.T) {
+	fw := &fakeWrapper{result: fakeResult{rowsAffected: 2}}
+	query := "UPDATE PERSON SET name = :name: WHERE id IN (:ids:)"
+	for _, ids := range [][]int{{1, 2}, {3, 4, 5}} {
+		result, err := Exec(context.Background(), fw, PostgresDialect, query, map[string]interface{}{"name": nil, "ids": ids})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count, _ := result.RowsAffected(); count != 2 {
+			t.Errorf("expected 2 rows affected, got %d", count)
+		}
+	}
+	expectedQueries := []string{
+		"UPDATE PERSON SET name = $1 WHERE id IN ($2, $3)",
+		"UPDATE PERSON SET name = $1 WHERE id IN ($2, $3, $4)",
+	}
+	expectedArgs := [][]interface{}{{nil, 1, 2}, {nil, 3, 4, 5}}
+	if !reflect.DeepEqual(fw.queries, expectedQueries) {
+		t.Errorf("expected %q, got %q", expectedQueries, fw.queries)
+	}
+	if !reflect.DeepEqual(fw.args, expectedArgs) {
+		t.Errorf("expected %#v, got %#v", expectedArgs, fw.args)
+	}
+
+	if _, err := Exec(context.Background(), fw, MySQLDialect, query, map[string]interface{}{"name": "bob", "ids": []int{1}}); err != nil {
+		t.Fatal(err)
+	}
+	if fw.queries[2] != "UPDATE PERSON SET name = ? WHERE id IN (?)" {
+		t.Errorf("unexpected query %q", fw.queries[2])
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// aggregatePlan describes how joined rows are grouped into a struct with many fields.
+//
+//	type Person struct {
+//		Id     int     `prof:"id,key"`
+//		Name   string  `prof:"name"`
+//		Orders []Order `prof:"order_,many"`
+//	}
+//
+// Rows with the same values in the key columns become one Person, and each row adds an Order
+// built from the columns that start with order_. A row whose order_ columns are all NULL,
+// as from a LEFT JOIN with no match, adds nothing.
+type aggregatePlan struct {
+	keyCols  []string
+	children []childPlan
+	//parent fills the parent's own fields; AfterLoad waits until its children are added
+	parent    Mapper
+	afterLoad bool
+}
+
+type childPlan struct {
+	pos         int
+	mapper      Mapper
+	colFieldMap map[string]fieldInfo
+	cols        []string
+	keyCols     []string
+}
+
+// buildAggregatePlan returns nil if returnType has no many fields.
+func buildAggregatePlan(returnType reflect.Type, opts buildOptions) (*aggregatePlan, error) {
+	var plan aggregatePlan
+	for i := 0; i < returnType.NumField(); i++ {
+		sf := returnType.Field(i)
+		tag := parseProfTag(sf)
+		if !tag.has("many") {
+			continue
+		}
+		if sf.Type.Kind() != reflect.Slice || sf.Type.Elem().Kind() != reflect.Struct {
+			return nil, fmt.Errorf("field %s of %v has the many option, so it must be a slice of structs", sf.Name, returnType)
+		}
+		childType := sf.Type.Elem()
+		for j := 0; j < childType.NumField(); j++ {
+			if parseProfTag(childType.Field(j)).has("many") {
+				return nil, fmt.Errorf("field %s of %v can't have the many option inside a many field", childType.Field(j).Name, childType)
+			}
+		}
+		prefix := tag.name
+		if prefix == "" {
+			if prefix = opts.fieldColumn(sf.Name); prefix != "" {
+				prefix += "_"
+			}
+		}
+		child := childPlan{
+			pos:         i,
+			mapper:      buildPrefixedMapper(childType, reflect.Zero(reflect.PtrTo(childType)), prefix, opts),
+			colFieldMap: buildColFieldMap(childType, prefix, opts),
+		}
+		child.cols, child.keyCols = mappedColumns(child.colFieldMap)
+		plan.children = append(plan.children, child)
+	}
+	if len(plan.children) == 0 {
+		return nil, nil
+	}
+	_, plan.keyCols = mappedColumns(buildColFieldMap(returnType, "", opts))
+	plan.parent = newStructMapper(returnType, reflect.Zero(reflect.PtrTo(returnType)), "", opts, false)
+	plan.afterLoad = hasAfterLoad(returnType)
+	if len(plan.keyCols) == 0 {
+		return nil, fmt.Errorf("%v has many fields, so at least one field needs the key option", returnType)
+	}
+	return &plan, nil
+}
+
+// mappedColumns returns the sorted names of all of the columns in colFieldMap, and of the ones for key fields.
+func mappedColumns(colFieldMap map[string]fieldInfo) ([]string, []string) {
+	var cols, keyCols []string
+	for k, v := range colFieldMap {
+		cols = append(cols, k)
+		if v.tag.has("key") {
+			keyCols = append(keyCols, k)
+		}
+	}
+	sort.Strings(cols)
+	sort.Strings(keyCols)
+	return cols, keyCols
+}
+
+type aggregateEntry struct {
+	val  reflect.Value
+	seen []map[string]bool
+}
+
+// mapAggregatedRows groups the rows by the plan's key columns. If single is true, only the rows
+// for the first key found are used.
+func mapAggregatedRows(returnType reflect.Type, plan *aggregatePlan, opts buildOptions, single bool, rows Rows, mapper Mapper, zeroVal reflect.Value) (reflect.Value, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return zeroVal, err
+	}
+	colPos := map[string]int{}
+	for k, v := range cols {
+		colPos[opts.columnKey(v)] = k
+	}
+	keyPos, err := columnPositions(colPos, plan.keyCols)
+	if err != nil {
+		return zeroVal, err
+	}
+	childCols := make([][]int, len(plan.children))
+	childKeys := make([][]int, len(plan.children))
+	for i, child := range plan.children {
+		for _, v := range child.cols {
+			if pos, ok := colPos[v]; ok {
+				childCols[i] = append(childCols[i], pos)
+			}
+		}
+		if childKeys[i], err = columnPositions(colPos, child.keyCols); err != nil {
+			return zeroVal, err
+		}
+	}
+
+	entries := map[string]*aggregateEntry{}
+	var order []*aggregateEntry
+	for rows.Next() {
+		vals := make([]interface{}, len(cols))
+		for i := 0; i < len(vals); i++ {
+			vals[i] = new(interface{})
+		}
+		err = rows.Scan(vals...)
+		if err != nil {
+			return zeroVal, err
+		}
+
+		key := rowKey(vals, keyPos)
+		entry, ok := entries[key]
+		if !ok {
+			if single && len(order) > 0 {
+				continue
+			}
+			curVal, err := plan.parent(cols, vals)
+			if err != nil {
+				return zeroVal, err
+			}
+			entry = &aggregateEntry{val: curVal, seen: make([]map[string]bool, len(plan.children))}
+			entries[key] = entry
+			order = append(order, entry)
+		}
+
+		for i, child := range plan.children {
+			if allNull(vals, childCols[i]) {
+				continue
+			}
+			//joining more than one child table repeats each child, so dedupe them when they have a key
+			if len(childKeys[i]) > 0 {
+				childKey := rowKey(vals, childKeys[i])
+				if entry.seen[i] == nil {
+					entry.seen[i] = map[string]bool{}
+				}
+				if entry.seen[i][childKey] {
+					continue
+				}
+				entry.seen[i][childKey] = true
+			}
+			childVal, err := child.mapper(cols, vals)
+			if err != nil {
+				return zeroVal, err
+			}
+			field := entry.val.Elem().Field(child.pos)
+			field.Set(reflect.Append(field, childVal.Elem()))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return zeroVal, err
+	}
+
+	if len(order) == 0 {
+		return zeroVal, nil
+	}
+	if plan.afterLoad {
+		for _, v := range order {
+			if err := runAfterLoad(v.val.Elem()); err != nil {
+				return zeroVal, err
+			}
+		}
+	}
+	if single {
+		return order[0].val, nil
+	}
+	outSlice := reflect.MakeSlice(reflect.SliceOf(returnType), 0, len(order))
+	for _, v := range order {
+		outSlice = reflect.Append(outSlice, v.val.Elem())
+	}
+	return outSlice, nil
+}
+
+func columnPositions(colPos map[string]int, names []string) ([]int, error) {
+	out := make([]int, 0, len(names))
+	for _, v := range names {
+		pos, ok := colPos[v]
+		if !ok {
+			return nil, fmt.Errorf("key column %s isn't in the query results", v)
+		}
+		out = append(out, pos)
+	}
+	return out, nil
+}
+
+func rowKey(vals []interface{}, positions []int) string {
+	var b bytes.Buffer
+	for _, pos := range positions {
+		val := normalizeValue(*vals[pos].(*interface{}))
+		fmt.Fprintf(&b, "%T:%v\x00", val, val)
+	}
+	return b.String()
+}
+
+func allNull(vals []interface{}, positions []int) bool {
+	for _, pos := range positions {
+		if *vals[pos].(*interface{}) != nil {
+			return false
+		}
+	}
+	return true
+}
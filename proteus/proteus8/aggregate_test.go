@@ -0,0 +1,85 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+type order struct {
+	Id    int `prof:"id,key"`
+	Total int `prof:"total"`
+}
+
+type tag struct {
+	Name string `prof:"name,key"`
+}
+
+type customer struct {
+	Id     int     `prof:"id,key"`
+	Name   string  `prof:"name"`
+	Orders []order `prof:"order_,many"`
+	Tags   []tag   `prof:"tag_,many"`
+}
+
+func TestAggregation(t *testing.T) {
+	var dao struct {
+		GetAll func(q Querier) ([]customer, error)        `proq:"SELECT p.id, p.name, o.id AS order_id, o.total AS order_total, t.name AS tag_name FROM PERSON p LEFT JOIN ORDERS o ON o.person_id = p.id LEFT JOIN TAGS t ON t.person_id = p.id"`
+		Get    func(q Querier, id int) (*customer, error) `proq:"SELECT p.id, p.name, o.id AS order_id, o.total AS order_total, t.name AS tag_name FROM PERSON p LEFT JOIN ORDERS o ON o.person_id = p.id LEFT JOIN TAGS t ON t.person_id = p.id WHERE p.id = :id:" prop:"id"`
+	}
+	if err := Build(&dao, Postgres); err != nil {
+		t.Fatal(err)
+	}
+	fw := &fakeWrapper{
+		cols: []string{"id", "name", "order_id", "order_total", "tag_name"},
+		rows: [][]interface{}{
+			{int64(1), "Fred", int64(10), int64(100), "a"},
+			{int64(1), "Fred", int64(10), int64(100), "b"},
+			{int64(1), "Fred", int64(11), int64(200), "a"},
+			{int64(1), "Fred", int64(11), int64(200), "b"},
+			{int64(2), "Bob", nil, nil, nil},
+		},
+	}
+	people, err := dao.GetAll(fw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []customer{
+		{Id: 1, Name: "Fred", Orders: []order{{10, 100}, {11, 200}}, Tags: []tag{{"a"}, {"b"}}},
+		{Id: 2, Name: "Bob"},
+	}
+	if !reflect.DeepEqual(people, expected) {
+		t.Errorf("expected %v, got %v", expected, people)
+	}
+
+	person, err := dao.Get(fw, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*person, expected[0]) {
+		t.Errorf("expected %v, got %v", expected[0], *person)
+	}
+}
+
+func TestAggregationInvalid(t *testing.T) {
+	type noKey struct {
+		Id     int     `prof:"id"`
+		Orders []order `prof:"order_,many"`
+	}
+	var dao struct {
+		GetAll func(q Querier) ([]noKey, error) `proq:"SELECT * FROM PERSON"`
+	}
+	if err := Build(&dao, Postgres); err == nil {
+		t.Error("expected a many field without a key to be rejected")
+	}
+
+	type notSlice struct {
+		Id    int   `prof:"id,key"`
+		Order order `prof:"order_,many"`
+	}
+	var dao2 struct {
+		GetAll func(q Querier) ([]notSlice, error) `proq:"SELECT * FROM PERSON"`
+	}
+	if err := Build(&dao2, Postgres); err == nil {
+		t.Error("expected a many field that isn't a slice to be rejected")
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+var timePtrType = reflect.PtrTo(timeType)
+
+// auditFields holds the created and updated fields of a struct, found by their prof tag options:
+//
+//	type Person struct {
+//		Id        int       `prof:"id"`
+//		CreatedAt time.Time `prof:"created_at,created"`
+//		UpdatedAt time.Time `prof:"updated_at,updated"`
+//	}
+//
+// When a struct with audit fields is passed to an Executor func whose query is an INSERT, a zero created field
+// and the updated field are set to the current time before the query runs. For an UPDATE, only the updated
+// field is set. A struct passed by pointer is changed in place, so the caller sees the times that were bound.
+type auditFields struct {
+	created [][]int
+	updated [][]int
+}
+
+func findAuditFields(structType reflect.Type, opts buildOptions) (auditFields, error) {
+	var af auditFields
+	for _, sf := range buildColFieldMap(structType, "", opts) {
+		created, updated := sf.tag.has("created"), sf.tag.has("updated")
+		if !created && !updated {
+			continue
+		}
+		if sf.fieldType != timeType && sf.fieldType != timePtrType {
+			return af, fmt.Errorf("audit field %s of %v must be a time.Time or *time.Time", sf.name, structType)
+		}
+		if created {
+			af.created = append(af.created, sf.index)
+		}
+		if updated {
+			af.updated = append(af.updated, sf.index)
+		}
+	}
+	return af, nil
+}
+
+// buildAuditHooks returns a func that fills the audit fields of the struct params of an INSERT or UPDATE,
+// or nil if there aren't any.
+func buildAuditHooks(funcType reflect.Type, query string, opts buildOptions) (func(args []reflect.Value) error, error) {
+	mask, err := maskQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	kind := statementKind(mask)
+	if kind != "INSERT" && kind != "UPDATE" {
+		return nil, nil
+	}
+	fields := map[reflect.Type]auditFields{}
+	var findErr error
+	positions := structParams(funcType, func(t reflect.Type) bool {
+		af, err := findAuditFields(t, opts)
+		if err != nil {
+			findErr = err
+			return false
+		}
+		fields[t] = af
+		return len(af.created) > 0 || len(af.updated) > 0
+	})
+	if findErr != nil {
+		return nil, findErr
+	}
+	if len(positions) == 0 {
+		return nil, nil
+	}
+	return func(args []reflect.Value) error {
+		now := opts.currentTime()
+		return updateStructArgs(args, positions, func(val reflect.Value) error {
+			af := fields[val.Type()]
+			if kind == "INSERT" {
+				for _, index := range af.created {
+					if field := fieldByIndexAlloc(val, index); isZeroTime(field) {
+						setTime(field, now)
+					}
+				}
+			}
+			for _, index := range af.updated {
+				setTime(fieldByIndexAlloc(val, index), now)
+			}
+			return nil
+		})
+	}, nil
+}
+
+func isZeroTime(field reflect.Value) bool {
+	if field.Kind() == reflect.Ptr {
+		return field.IsNil() || field.Elem().Interface().(time.Time).IsZero()
+	}
+	return field.Interface().(time.Time).IsZero()
+}
+
+func setTime(field reflect.Value, t time.Time) {
+	if field.Kind() == reflect.Ptr {
+		field.Set(reflect.ValueOf(&t))
+		return
+	}
+	field.Set(reflect.ValueOf(t))
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type auditPerson struct {
+	Name      string     `prof:"name"`
+	CreatedAt time.Time  `prof:"created_at,created"`
+	UpdatedAt *time.Time `prof:"updated_at,updated"`
+}
+
+func TestAuditFields(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	var dao struct {
+		Insert    func(e Executor, people []auditPerson) (int64, error)  `proq:"INSERT INTO PERSON(name, created_at, updated_at) VALUES :people:" prop:"people"`
+		InsertOne func(e Executor, people []*auditPerson) (int64, error) `proq:"INSERT INTO PERSON(name, created_at, updated_at) VALUES :people:" prop:"people"`
+		Update    func(e Executor, people []auditPerson) (int64, error)  `proq:"UPDATE PERSON SET updated_at = v.updated_at FROM (VALUES :people:) AS v(name, created_at, updated_at) WHERE PERSON.name = v.name" prop:"people"`
+		Delete    func(e Executor, people []auditPerson) (int64, error)  `proq:"DELETE FROM PERSON WHERE (name, created_at, updated_at) IN (:people:)" prop:"people"`
+	}
+	if err := Build(&dao, Postgres, WithClock(func() time.Time { return now })); err != nil {
+		t.Fatal(err)
+	}
+	earlier := now.Add(-time.Hour)
+	people := []auditPerson{{Name: "fred"}, {Name: "bob", CreatedAt: earlier}}
+	fw := &fakeWrapper{}
+	if _, err := dao.Insert(fw, people); err != nil {
+		t.Fatal(err)
+	}
+	expected := []interface{}{"fred", now, &now, "bob", earlier, &now}
+	if !reflect.DeepEqual(fw.args[0], expected) {
+		t.Errorf("expected %v, got %v", expected, fw.args[0])
+	}
+	if !people[0].CreatedAt.IsZero() {
+		t.Error("expected the caller's slice to be unchanged")
+	}
+
+	p := &auditPerson{Name: "fred"}
+	if _, err := dao.InsertOne(fw, []*auditPerson{p}); err != nil {
+		t.Fatal(err)
+	}
+	if !p.CreatedAt.Equal(now) || p.UpdatedAt == nil || !p.UpdatedAt.Equal(now) {
+		t.Errorf("expected the times to be set on a pointer, got %+v", p)
+	}
+
+	if _, err := dao.Update(fw, people); err != nil {
+		t.Fatal(err)
+	}
+	expected = []interface{}{"fred", time.Time{}, &now, "bob", earlier, &now}
+	if !reflect.DeepEqual(fw.args[2], expected) {
+		t.Errorf("expected only updated_at to be set, got %v", fw.args[2])
+	}
+	if _, err := dao.Delete(fw, people); err != nil {
+		t.Fatal(err)
+	}
+	expected = []interface{}{"fred", time.Time{}, (*time.Time)(nil), "bob", earlier, (*time.Time)(nil)}
+	if !reflect.DeepEqual(fw.args[3], expected) {
+		t.Errorf("expected no times to be set for a delete, got %v", fw.args[3])
+	}
+}
+
+func TestAuditFieldType(t *testing.T) {
+	type badAudit struct {
+		CreatedAt string `prof:"created_at,created"`
+	}
+	var dao struct {
+		Insert func(e Executor, people []badAudit) (int64, error) `proq:"INSERT INTO PERSON(created_at) VALUES :people:" prop:"people"`
+	}
+	if err := Build(&dao, Postgres); err == nil {
+		t.Error("expected an error for an audit field that isn't a time")
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// chunkArgs splits a call whose slice parameters expand to more than maxParams placeholders.
+// The longest slice is cut into pieces, and each returned set of args runs as a separate query
+// with every other parameter unchanged. If maxParams is 0, or the call fits, the args are returned as-is.
+//
+// The longest slice can only be cut if it appears once in the query, as the list of an IN predicate that
+// is ANDed into the WHERE clause, so that each row matches in at most one chunk. Otherwise, as with
+// NOT IN or an OR, the chunks would match rows the whole query doesn't, or match a row more than once,
+// and the call returns an error.
+// Chunking still changes the meaning of queries that aggregate, sort, or limit their results,
+// and the chunks don't run in a transaction unless the Executor or Querier is one.
+func chunkArgs(funcArgs []reflect.Value, paramOrder []paramInfo, maxParams int) ([][]reflect.Value, error) {
+	if maxParams <= 0 {
+		return [][]reflect.Value{funcArgs}, nil
+	}
+
+	total := 0
+	longest := -1
+	for _, v := range paramOrder {
+		if !v.isSlice {
+			total++
+			continue
+		}
+		curLen := funcArgs[v.posInParams].Len()
+		total += curLen * v.width()
+		if longest == -1 || curLen > funcArgs[longest].Len() {
+			longest = v.posInParams
+		}
+	}
+	if total <= maxParams {
+		return [][]reflect.Value{funcArgs}, nil
+	}
+
+	longestLen := funcArgs[longest].Len()
+	var split []paramInfo
+	for _, v := range paramOrder {
+		if v.isSlice && v.posInParams == longest {
+			split = append(split, v)
+		}
+	}
+	if len(split) != 1 || !split[0].splittable {
+		return nil, fmt.Errorf("query needs %d parameters, and %s can't be split to fit the limit of %d: "+
+			"a slice can only be split if it is used once, as the list of an IN predicate ANDed into the WHERE clause", total, split[0].name, maxParams)
+	}
+	perElem := split[0].width()
+	chunkSize := (maxParams - (total - longestLen*perElem)) / perElem
+	if chunkSize < 1 {
+		return nil, fmt.Errorf("query needs %d parameters, which can't be split to fit the limit of %d", total, maxParams)
+	}
+
+	var out [][]reflect.Value
+	for start := 0; start < longestLen; start += chunkSize {
+		end := start + chunkSize
+		if end > longestLen {
+			end = longestLen
+		}
+		chunk := make([]reflect.Value, len(funcArgs))
+		copy(chunk, funcArgs)
+		chunk[longest] = funcArgs[longest].Slice(start, end)
+		out = append(out, chunk)
+	}
+	return out, nil
+}
+
+// splittableParams reports, for each of the param tokens lexed from query, if chunkArgs can split the param.
+func splittableParams(query string, tokens []token) (map[int]bool, error) {
+	mask, offsets, err := maskTokens(query)
+	if err != nil {
+		return nil, err
+	}
+	out := map[int]bool{}
+	for k, v := range tokens {
+		if v.kind == paramToken {
+			out[k] = splittable(mask, offsets[k], offsets[k]+utf8.RuneCountInString(v.value)+2)
+		}
+	}
+	return out, nil
+}
+
+// splittable reports if the param at mask[start:end] is the whole list of an IN predicate that is ANDed into
+// the WHERE clause of the statement, directly or through parentheses that only group. Those are the
+// predicates where the rows matched by the whole list are the rows matched by any piece of it.
+func splittable(mask string, start, end int) bool {
+	before, after := mask[:start], mask[end:]
+	pos := skipSpaceBack(before, len(before))
+	closeParen := strings.IndexFunc(after, func(r rune) bool { return !unicode.IsSpace(r) })
+	if pos == 0 || before[pos-1] != '(' || closeParen == -1 || after[closeParen] != ')' {
+		return false
+	}
+	pos = skipSpaceBack(before, pos-1)
+	word, wordStart := wordBefore(before, pos)
+	if !strings.EqualFold(word, "IN") {
+		return false
+	}
+	pos = skipSpaceBack(before, wordStart)
+	if word, _ := wordBefore(before, pos); strings.EqualFold(word, "NOT") {
+		return false
+	}
+	itemStart, itemEnd := operandStart(before, pos), end+closeParen+1
+	if itemStart == -1 {
+		return false
+	}
+
+	//work out from the predicate, through each pair of parentheses around it, to the WHERE clause
+	for {
+		open, closing := enclosingParens(mask, itemStart, itemEnd)
+		prev := skipSpaceBack(mask, itemStart)
+		prevWord, _ := wordBefore(mask, prev)
+		next := itemEnd
+		for next < len(mask) && unicode.IsSpace(rune(mask[next])) {
+			next++
+		}
+		if open == -1 {
+			//the top level: the item follows the WHERE, and nothing after the WHERE is ORed with it
+			where := strings.LastIndex(levelWords(mask[:itemStart]), " WHERE ")
+			if where == -1 || (!strings.EqualFold(prevWord, "WHERE") && !strings.EqualFold(prevWord, "AND")) {
+				return false
+			}
+			if next < len(mask) && mask[next] != ';' && !isIdentByte(mask[next]) {
+				return false
+			}
+			rest := levelWords(mask[:itemStart])[where:] + levelWords(mask[itemEnd:])
+			for _, v := range []string{" OR ", " UNION ", " INTERSECT ", " EXCEPT "} {
+				if strings.Contains(rest, v) {
+					return false
+				}
+			}
+			return true
+		}
+		//parentheses that only group: the item is ANDed with the rest of what's inside them
+		if prev != open+1 && !strings.EqualFold(prevWord, "AND") {
+			return false
+		}
+		if next != closing && !strings.EqualFold(nextWord(mask, next), "AND") {
+			return false
+		}
+		if strings.Contains(levelWords(mask[open+1:closing]), " OR ") {
+			return false
+		}
+		itemStart, itemEnd = open, closing+1
+	}
+}
+
+// enclosingParens returns the positions of the parentheses around mask[start:end], or -1 and len(mask)
+// if there aren't any.
+func enclosingParens(mask string, start, end int) (int, int) {
+	open, depth := -1, 0
+	for i := start - 1; i >= 0 && open == -1; i-- {
+		switch mask[i] {
+		case ')':
+			depth++
+		case '(':
+			if depth == 0 {
+				open = i
+			}
+			depth--
+		}
+	}
+	if open == -1 {
+		return -1, len(mask)
+	}
+	depth = 0
+	for i := end; i < len(mask); i++ {
+		switch mask[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return open, i
+			}
+			depth--
+		}
+	}
+	return -1, len(mask)
+}
+
+// levelWords returns the words in s that aren't inside parentheses, in upper case, each with a space
+// before and after it.
+func levelWords(s string) string {
+	var b strings.Builder
+	b.WriteString(" ")
+	depth := 0
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == '(':
+			depth++
+			i++
+		case c == ')':
+			depth--
+			i++
+		case isIdentByte(c):
+			j := i
+			for j < len(s) && isIdentByte(s[j]) {
+				j++
+			}
+			if depth == 0 {
+				b.WriteString(strings.ToUpper(s[i:j]) + " ")
+			}
+			i = j
+		default:
+			i++
+		}
+	}
+	return b.String()
+}
+
+// nextWord returns the word that starts at start.
+func nextWord(s string, start int) string {
+	end := start
+	for end < len(s) && isIdentByte(s[end]) {
+		end++
+	}
+	return s[start:end]
+}
+
+func isIdentByte(c byte) bool {
+	return c >= utf8.RuneSelf || isIdentRune(rune(c))
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChunking(t *testing.T) {
+	var dao struct {
+		GetByAge func(q Querier, name string, ages []int) ([]Person, error) `proq:"SELECT * FROM PERSON WHERE name = :name: AND age IN (:ages:)" prop:"name,ages"`
+		GetOne   func(q Querier, ids []int) (*Person, error)                `proq:"SELECT * FROM PERSON WHERE id IN (:ids:)" prop:"ids"`
+		Delete   func(e Executor, ids []int) (int64, error)                 `proq:"DELETE FROM PERSON WHERE id IN (:ids:)" prop:"ids"`
+		Twice    func(q Querier, ids []int, name string) ([]Person, error)  `proq:"SELECT * FROM PERSON WHERE id IN (:ids:) OR age IN (:ids:) OR name = :name:" prop:"ids,name"`
+	}
+	if err := Build(&dao, Postgres, WithMaxParams(4)); err != nil {
+		t.Fatal(err)
+	}
+
+	fw := &fakeWrapper{
+		cols: []string{"id", "name", "age"},
+		rows: [][]interface{}{{int64(1), "Fred", int64(20)}},
+	}
+	people, err := dao.GetByAge(fw, "Fred", []int{1, 2, 3, 4, 5, 6, 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedQueries := []string{
+		"SELECT * FROM PERSON WHERE name = $1 AND age IN ($2, $3, $4)",
+		"SELECT * FROM PERSON WHERE name = $1 AND age IN ($2, $3, $4)",
+		"SELECT * FROM PERSON WHERE name = $1 AND age IN ($2)",
+	}
+	if !reflect.DeepEqual(fw.queries, expectedQueries) {
+		t.Errorf("expected %v, got %v", expectedQueries, fw.queries)
+	}
+	expectedArgs := [][]interface{}{{"Fred", 1, 2, 3}, {"Fred", 4, 5, 6}, {"Fred", 7}}
+	if !reflect.DeepEqual(fw.args, expectedArgs) {
+		t.Errorf("expected %v, got %v", expectedArgs, fw.args)
+	}
+	if len(people) != 3 {
+		t.Errorf("expected results from 3 queries, got %v", people)
+	}
+
+	fw = &fakeWrapper{cols: []string{"id", "name", "age"}, rows: [][]interface{}{{int64(1), "Fred", int64(20)}}}
+	person, err := dao.GetOne(fw, []int{1, 2, 3, 4, 5, 6, 7, 8, 9})
+	if err != nil || person == nil || person.Name != "Fred" || len(fw.queries) != 1 {
+		t.Errorf("expected the first chunk to supply the result, got %v, %v, %v", person, err, fw.queries)
+	}
+
+	fw = &fakeWrapper{result: fakeResult{rowsAffected: 2}}
+	count, err := dao.Delete(fw, []int{1, 2, 3, 4, 5, 6, 7, 8, 9})
+	if err != nil || count != 6 || len(fw.queries) != 3 {
+		t.Errorf("expected RowsAffected summed over 3 queries, got %v, %v, %v", count, err, fw.queries)
+	}
+
+	fw = &fakeWrapper{}
+	if _, err := dao.Twice(fw, []int{1, 2, 3}, "Fred"); err == nil || len(fw.queries) != 0 {
+		t.Errorf("expected an error for a slice that is used twice, got %v, %v", err, fw.queries)
+	}
+
+	var tooSmall struct {
+		GetByAge func(q Querier, name string, ages []int) ([]Person, error) `proq:"SELECT * FROM PERSON WHERE name = :name: AND age IN (:ages:)" prop:"name,ages"`
+	}
+	if err := Build(&tooSmall, Postgres, WithMaxParams(1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tooSmall.GetByAge(fw, "Fred", []int{1, 2}); err == nil {
+		t.Error("expected an error when the query can't fit the limit")
+	}
+}
+
+func TestChunkArgsUnlimited(t *testing.T) {
+	args := []reflect.Value{reflect.ValueOf(0), reflect.ValueOf([]int{1, 2, 3})}
+	chunks, err := chunkArgs(args, []paramInfo{{name: "ids", posInParams: 1, isSlice: true}}, 0)
+	if err != nil || len(chunks) != 1 {
+		t.Errorf("expected a single chunk, got %v, %v", chunks, err)
+	}
+}
+
+func TestChunkingOnlyInAndedIn(t *testing.T) {
+	var dao struct {
+		NotIn     func(q Querier, ids []int) ([]Person, error)          `proq:"SELECT * FROM PERSON WHERE id NOT IN (:ids:)" prop:"ids"`
+		DeleteNot func(e Executor, ids []int) (int64, error)            `proq:"DELETE FROM PERSON WHERE id NOT IN (:ids:)" prop:"ids"`
+		Or        func(q Querier, x, y []int) ([]Person, error)         `proq:"SELECT * FROM PERSON WHERE a IN (:x:) OR b IN (:y:)" prop:"x,y"`
+		Grouped   func(q Querier, age int, ids []int) ([]Person, error) `proq:"SELECT * FROM PERSON WHERE (age = :age: AND (id IN (:ids:))) AND name IS NOT NULL ORDER BY id" prop:"age,ids"`
+	}
+	if err := Build(&dao, Postgres, WithMaxParams(2)); err != nil {
+		t.Fatal(err)
+	}
+	fw := &fakeWrapper{result: fakeResult{rowsAffected: 1}}
+	if _, err := dao.NotIn(fw, []int{1, 2, 3}); err == nil {
+		t.Error("expected an error for splitting a NOT IN list")
+	}
+	if _, err := dao.DeleteNot(fw, []int{1, 2, 3}); err == nil {
+		t.Error("expected an error for splitting a NOT IN list")
+	}
+	if _, err := dao.Or(fw, []int{1}, []int{2, 3}); err == nil {
+		t.Error("expected an error for splitting an IN list in an OR")
+	}
+	if len(fw.queries) != 0 {
+		t.Errorf("expected no queries, got %v", fw.queries)
+	}
+	if _, err := dao.NotIn(fw, []int{1, 2}); err != nil {
+		t.Errorf("expected a NOT IN list that fits to run, got %v", err)
+	}
+
+	fw = &fakeWrapper{}
+	if _, err := dao.Grouped(fw, 20, []int{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"SELECT * FROM PERSON WHERE (age = $1 AND (id IN ($2))) AND name IS NOT NULL ORDER BY id",
+		"SELECT * FROM PERSON WHERE (age = $1 AND (id IN ($2))) AND name IS NOT NULL ORDER BY id",
+		"SELECT * FROM PERSON WHERE (age = $1 AND (id IN ($2))) AND name IS NOT NULL ORDER BY id",
+	}
+	if !reflect.DeepEqual(fw.queries, expected) {
+		t.Errorf("expected %q, got %q", expected, fw.queries)
+	}
+}
+
+func TestSplittable(t *testing.T) {
+	data := []struct {
+		query    string
+		expected bool
+	}{
+		{"SELECT * FROM PERSON WHERE id IN (:ids:)", true},
+		{"SELECT * FROM PERSON WHERE name = :name: AND id IN ( :ids: ) ORDER BY id;", true},
+		{"SELECT * FROM PERSON p WHERE (p.tenant_id, p.user_id) IN (:ids:) AND age > 3", true},
+		{"UPDATE PERSON SET age = :age: WHERE (id IN (:ids:)) AND deleted_at IS NULL", true},
+		{"SELECT * FROM PERSON WHERE lower(:name:) IN (:ids:)", true},
+		{"SELECT * FROM PERSON WHERE id NOT IN (:ids:)", false},
+		{"SELECT * FROM PERSON WHERE NOT (id IN (:ids:))", false},
+		{"SELECT * FROM PERSON WHERE id IN (:ids:) OR age = 3", false},
+		{"SELECT * FROM PERSON WHERE (id IN (:ids:) OR age = 3) AND name = 'x'", false},
+		{"SELECT * FROM PERSON WHERE id IN (:ids:) UNION SELECT * FROM OLD_PERSON", false},
+		{"SELECT * FROM PERSON WHERE x IN (SELECT y FROM T WHERE id IN (:ids:))", false},
+		{"SELECT * FROM PERSON WHERE coalesce(id IN (:ids:), false)", false},
+		{"SELECT * FROM PERSON WHERE id = ANY(:ids:)", false},
+		{"INSERT INTO PERSON(id) VALUES (:ids:)", false},
+		{"SELECT * FROM PERSON JOIN T ON T.id IN (:ids:)", false},
+	}
+	for _, v := range data {
+		tokens, err := lexQuery(v.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		splittable, err := splittableParams(v.query, tokens)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, tok := range tokens {
+			if tok.kind == paramToken && tok.value == "ids" && splittable[k] != v.expected {
+				t.Errorf("%s: expected %v, got %v", v.query, v.expected, splittable[k])
+			}
+		}
+	}
+}
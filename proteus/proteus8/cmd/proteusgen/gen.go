@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/printer"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+const (
+	queryDirective  = "//proteus:query"
+	paramsDirective = "//proteus:params"
+)
+
+type iface struct {
+	Name     string
+	ImplName string
+	NewName  string
+	Methods  []method
+}
+
+type method struct {
+	Name      string
+	FieldName string
+	// Params is the method's parameter list, and FuncType the type of the func built for it.
+	Params   string
+	Results  string
+	FuncType string
+	// Ctx is the name of the leading context.Context param, if there is one.
+	Ctx string
+	// Zeros declares a variable for each result but the error, for returning when the context is done.
+	Zeros   []string
+	ZeroIDs string
+	Args    string
+	Query   string
+	Prop    string
+	// Recv is the receiver name, chosen so it doesn't shadow a param.
+	Recv string
+}
+
+// generator turns interfaces into the source of types that implement them.
+type generator struct {
+	fset    *token.FileSet
+	queries map[string]string
+	//imports maps the package names used in the source files to their paths
+	imports map[string]string
+	used    map[string]bool
+}
+
+func newGenerator(fset *token.FileSet, queries map[string]string) *generator {
+	return &generator{fset: fset, queries: queries, imports: map[string]string{}, used: map[string]bool{}}
+}
+
+// findInterfaces returns the interfaces in files with the given names, or every interface with a
+// proteus:query directive (or side file query) on at least one method if names is empty.
+func (g *generator) findInterfaces(files []*ast.File, names []string) ([]iface, error) {
+	wanted := map[string]bool{}
+	for _, v := range names {
+		wanted[v] = true
+	}
+	var out []iface
+	for _, f := range files {
+		for _, imp := range f.Imports {
+			path, _ := strconv.Unquote(imp.Path.Value)
+			name := path[strings.LastIndex(path, "/")+1:]
+			if imp.Name != nil {
+				name = imp.Name.Name
+			}
+			g.imports[name] = path
+		}
+		for _, decl := range f.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				it, ok := ts.Type.(*ast.InterfaceType)
+				if !ok {
+					continue
+				}
+				if len(names) > 0 && !wanted[ts.Name.Name] {
+					continue
+				}
+				if len(names) == 0 && !g.hasQueries(ts.Name.Name, it) {
+					continue
+				}
+				delete(wanted, ts.Name.Name)
+				i, err := g.buildInterface(ts.Name.Name, it)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, i)
+			}
+		}
+	}
+	for k := range wanted {
+		return nil, fmt.Errorf("interface %s not found", k)
+	}
+	return out, nil
+}
+
+func (g *generator) hasQueries(name string, it *ast.InterfaceType) bool {
+	for _, m := range it.Methods.List {
+		if len(m.Names) == 0 {
+			continue
+		}
+		if _, ok := g.queries[name+"."+m.Names[0].Name]; ok {
+			return true
+		}
+		for _, c := range docLines(m.Doc) {
+			if strings.HasPrefix(c, queryDirective) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func docLines(cg *ast.CommentGroup) []string {
+	if cg == nil {
+		return nil
+	}
+	out := make([]string, len(cg.List))
+	for k, v := range cg.List {
+		out[k] = v.Text
+	}
+	return out
+}
+
+func (g *generator) buildInterface(name string, it *ast.InterfaceType) (iface, error) {
+	out := iface{Name: name, ImplName: lowerFirst(name), NewName: "New" + upperFirst(name)}
+	if out.ImplName == name {
+		out.ImplName = name + "Impl"
+	}
+	for _, m := range it.Methods.List {
+		ft, ok := m.Type.(*ast.FuncType)
+		if !ok || len(m.Names) == 0 {
+			return out, fmt.Errorf("%s: embedded interfaces aren't supported", name)
+		}
+		meth, err := g.buildMethod(name, m.Names[0].Name, ft, docLines(m.Doc))
+		if err != nil {
+			return out, err
+		}
+		out.Methods = append(out.Methods, meth)
+	}
+	return out, nil
+}
+
+func (g *generator) buildMethod(ifaceName, name string, ft *ast.FuncType, doc []string) (method, error) {
+	fullName := ifaceName + "." + name
+	m := method{Name: name, FieldName: lowerFirst(name) + "Func"}
+	var queryLines []string
+	prop, hasProp := "", false
+	for _, v := range doc {
+		switch {
+		case strings.HasPrefix(v, queryDirective):
+			queryLines = append(queryLines, strings.TrimSpace(strings.TrimPrefix(v, queryDirective)))
+		case strings.HasPrefix(v, paramsDirective):
+			prop, hasProp = strings.TrimSpace(strings.TrimPrefix(v, paramsDirective)), true
+		}
+	}
+	m.Query = strings.Join(queryLines, " ")
+	if q, ok := g.queries[fullName]; ok {
+		m.Query = q
+	}
+	if m.Query == "" {
+		return m, fmt.Errorf("%s has no query", fullName)
+	}
+
+	type param struct{ name, typ string }
+	var params []param
+	for _, f := range ft.Params.List {
+		if _, ok := f.Type.(*ast.Ellipsis); ok {
+			return m, fmt.Errorf("%s: variadic params aren't supported", fullName)
+		}
+		typ := g.typeString(f.Type)
+		if len(f.Names) == 0 {
+			params = append(params, param{typ: typ})
+		}
+		for _, n := range f.Names {
+			params = append(params, param{name: n.Name, typ: typ})
+		}
+	}
+	hasParam := func(name string) bool {
+		for _, p := range params {
+			if p.name == name {
+				return true
+			}
+		}
+		return false
+	}
+	var methodParams []string
+	for k := range params {
+		if params[k].name == "" || params[k].name == "_" {
+			params[k].name = fmt.Sprintf("arg%d", k)
+		}
+		methodParams = append(methodParams, params[k].name+" "+params[k].typ)
+	}
+	m.Params = strings.Join(methodParams, ", ")
+	m.Recv = "s"
+	for k := 0; hasParam(m.Recv); k++ {
+		m.Recv = fmt.Sprintf("s%d", k)
+	}
+	if len(params) > 0 && params[0].typ == "context.Context" {
+		m.Ctx = params[0].name
+		params = params[1:]
+	}
+	if len(params) == 0 {
+		return m, fmt.Errorf("%s needs an Executor or Querier param", fullName)
+	}
+
+	var funcParams, args, names []string
+	for _, p := range params {
+		funcParams = append(funcParams, p.name+" "+p.typ)
+		args = append(args, p.name)
+	}
+	for _, p := range params[1:] {
+		names = append(names, p.name)
+	}
+	m.Args = strings.Join(args, ", ")
+	m.Prop = strings.Join(names, ",")
+	if hasProp {
+		m.Prop = prop
+	}
+
+	var results []string
+	if ft.Results != nil {
+		for _, f := range ft.Results.List {
+			typ := g.typeString(f.Type)
+			for i := 0; i < len(f.Names) || (i == 0 && len(f.Names) == 0); i++ {
+				results = append(results, typ)
+			}
+		}
+	}
+	if len(results) == 0 || results[len(results)-1] != "error" {
+		return m, fmt.Errorf("%s must return an error as its last value", fullName)
+	}
+	var zeroIDs []string
+	for k, v := range results[:len(results)-1] {
+		id := fmt.Sprintf("r%d", k)
+		m.Zeros = append(m.Zeros, id+" "+v)
+		zeroIDs = append(zeroIDs, id)
+	}
+	m.ZeroIDs = strings.Join(append(zeroIDs, m.Ctx+".Err()"), ", ")
+	m.Results = strings.Join(results, ", ")
+	if len(results) > 1 {
+		m.Results = "(" + m.Results + ")"
+	}
+	m.FuncType = fmt.Sprintf("func(%s) %s", strings.Join(funcParams, ", "), m.Results)
+	return m, nil
+}
+
+// typeString prints a type as it appears in the source, and records the packages it uses.
+func (g *generator) typeString(expr ast.Expr) string {
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok {
+				g.used[id.Name] = true
+			}
+		}
+		return true
+	})
+	var b bytes.Buffer
+	printer.Fprint(&b, g.fset, expr)
+	return b.String()
+}
+
+func (g *generator) importList() ([]string, error) {
+	var out []string
+	for k := range g.used {
+		path, ok := g.imports[k]
+		if !ok {
+			return nil, fmt.Errorf("no import found for package %s", k)
+		}
+		if path[strings.LastIndex(path, "/")+1:] == k {
+			out = append(out, strconv.Quote(path))
+		} else {
+			out = append(out, k+" "+strconv.Quote(path))
+		}
+	}
+	if !g.used["fmt"] {
+		out = append(out, `"fmt"`)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+var fileTemplate = template.Must(template.New("file").Funcs(template.FuncMap{"quote": strconv.Quote}).Parse(`// Code generated by proteusgen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{range .Imports}}	{{.}}
+{{end}})
+{{range .Interfaces}}{{$iface := .}}
+// {{.ImplName}} implements {{.Name}} with funcs built by BuildFunc.
+type {{.ImplName}} struct {
+{{range .Methods}}	{{.FieldName}} {{.FuncType}}
+{{end}}}
+
+// {{.NewName}} returns a {{.Name}} that runs the queries from its proteus:query directives.
+func {{.NewName}}(paramAdapter ParamAdapter, options ...Option) ({{.Name}}, error) {
+	var out {{.ImplName}}
+	var err error
+{{range .Methods}}	out.{{.FieldName}}, err = BuildFunc[{{.FuncType}}]({{quote .Query}}, {{quote .Prop}}, paramAdapter, options...)
+	if err != nil {
+		return nil, fmt.Errorf("{{$iface.Name}}.{{.Name}}: %w", err)
+	}
+{{end}}	return &out, nil
+}
+{{range .Methods}}
+func ({{.Recv}} *{{$iface.ImplName}}) {{.Name}}({{.Params}}) {{.Results}} {
+{{if .Ctx}}	if {{.Ctx}}.Err() != nil {
+{{range .Zeros}}		var {{.}}
+{{end}}		return {{.ZeroIDs}}
+	}
+{{end}}	return {{.Recv}}.{{.FieldName}}({{.Args}})
+}
+{{end}}{{end}}`))
+
+// generate returns the formatted source for the interfaces.
+func (g *generator) generate(pkg string, ifaces []iface) ([]byte, error) {
+	if len(ifaces) == 0 {
+		return nil, errors.New("no interfaces with proteus:query directives found")
+	}
+	imports, err := g.importList()
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	err = fileTemplate.Execute(&b, struct {
+		Package    string
+		Imports    []string
+		Interfaces []iface
+	}{pkg, imports, ifaces})
+	if err != nil {
+		return nil, err
+	}
+	out, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated invalid code: %w\n%s", err, b.String())
+	}
+	return out, nil
+}
+
+func lowerFirst(s string) string {
+	r := []rune(s)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+func upperFirst(s string) string {
+	r := []rune(s)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGeneratedFileIsCurrent regenerates the PersonStore implementation and compares it to the checked in copy.
+func TestGeneratedFileIsCurrent(t *testing.T) {
+	dir := filepath.Join("..", "..")
+	output := filepath.Join(t.TempDir(), "personstore_proteus.go")
+	if err := run(dir, "PersonStore", output, ""); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := os.ReadFile(filepath.Join(dir, "personstore_proteus.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, expected) {
+		t.Errorf("personstore_proteus.go is out of date; run go generate. Got:\n%s", got)
+	}
+}
+
+func generateSource(t *testing.T, src string, queries map[string]string) (string, error) {
+	t.Helper()
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "src.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := newGenerator(fset, queries)
+	ifaces, err := g.findInterfaces([]*ast.File{f}, nil)
+	if err != nil {
+		return "", err
+	}
+	out, err := g.generate("main", ifaces)
+	return string(out), err
+}
+
+func TestSideFileAndUnnamedParams(t *testing.T) {
+	src := `package main
+
+import (
+	"context"
+	sq "database/sql"
+)
+
+type OrderStore interface {
+	//proteus:params id
+	Get(Querier, int) (sq.NullString, error)
+	Count(ctx context.Context, q Querier) (int, error)
+}
+`
+	out, err := generateSource(t, src, map[string]string{
+		"OrderStore.Get":   "SELECT name FROM ORDERS WHERE id = :id:",
+		"OrderStore.Count": "SELECT COUNT(*) FROM ORDERS",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{
+		`sq "database/sql"`,
+		`func (s *orderStore) Get(arg0 Querier, arg1 int) (sq.NullString, error) {`,
+		`BuildFunc[func(arg0 Querier, arg1 int) (sq.NullString, error)]("SELECT name FROM ORDERS WHERE id = :id:", "id", paramAdapter, options...)`,
+		`BuildFunc[func(q Querier) (int, error)]("SELECT COUNT(*) FROM ORDERS", "", paramAdapter, options...)`,
+	} {
+		if !strings.Contains(out, v) {
+			t.Errorf("expected generated code to contain %q, got:\n%s", v, out)
+		}
+	}
+}
+
+func TestReceiverNameAndFmtImport(t *testing.T) {
+	src := `package main
+
+import (
+	"context"
+	"fmt"
+)
+
+type Finder interface {
+	//proteus:query SELECT * FROM PERSON WHERE name = :s:
+	Search(ctx context.Context, q Querier, s string) ([]Person, error)
+	//proteus:query SELECT name FROM PERSON WHERE id = :s0:
+	Name(q Querier, s int, s0 int) (fmt.Stringer, error)
+}
+`
+	out, err := generateSource(t, src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{
+		`func (s0 *finder) Search(ctx context.Context, q Querier, s string) ([]Person, error) {`,
+		`return s0.searchFunc(q, s)`,
+		`func (s1 *finder) Name(q Querier, s int, s0 int) (fmt.Stringer, error) {`,
+		`return s1.nameFunc(q, s, s0)`,
+	} {
+		if !strings.Contains(out, v) {
+			t.Errorf("expected generated code to contain %q, got:\n%s", v, out)
+		}
+	}
+	if count := strings.Count(out, `"fmt"`); count != 1 {
+		t.Errorf("expected fmt to be imported once, got %d times:\n%s", count, out)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	data := []string{
+		`package main
+type S interface {
+	//proteus:query SELECT 1
+	Get(q Querier, ids ...int) error
+}`,
+		`package main
+type S interface {
+	//proteus:query SELECT 1
+	Get(q Querier) (int, error)
+	Other(q Querier) error
+}`,
+		`package main
+type S interface {
+	//proteus:query SELECT 1
+	Get(q Querier) int
+}`,
+		`package main
+type S interface {
+	Get(q Querier) error
+}`,
+	}
+	for _, v := range data {
+		if _, err := generateSource(t, v, nil); err == nil {
+			t.Errorf("expected an error for %s", v)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// Command proteusgen generates types that implement DAO interfaces, as an alternative to structs of func fields.
+//
+// Each method of the interface gets its query from a proteus:query directive in its doc comment:
+//
+//	type PersonStore interface {
+//		// Get returns the person with the id.
+//		//proteus:query SELECT * FROM PERSON WHERE id = :id:
+//		Get(ctx context.Context, q Querier, id int) (*Person, error)
+//	}
+//
+// A long query can be split over several proteus:query lines. Queries can also come from a JSON side file,
+// passed with -queries, that maps Interface.Method to the query; these replace the directives.
+//
+// The params after the Executor or Querier are named in the query by their names in the method,
+// or by the names in a proteus:params directive, which is written like a prop tag.
+// A leading context.Context param is checked before the query runs.
+//
+// For PersonStore, proteusgen writes a personStore type and a NewPersonStore(paramAdapter, options...) func
+// that builds each method's query with BuildFunc, so the queries are parsed and the results mapped
+// exactly as they are for Build. The generated file goes in the same package as the interface,
+// which has to be the package that holds proteus.
+//
+// Usage:
+//
+//	//go:generate go run ./cmd/proteusgen -type PersonStore -output personstore_proteus.go
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("proteusgen: ")
+	typeNames := flag.String("type", "", "comma-separated interface names; the default is every interface with a proteus:query directive")
+	output := flag.String("output", "", "output file; the default is <first type>_proteus.go in the package directory")
+	queriesFile := flag.String("queries", "", "JSON file mapping Interface.Method to its query")
+	flag.Parse()
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+	if err := run(dir, *typeNames, *output, *queriesFile); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(dir, typeNames, output, queriesFile string) error {
+	queries := map[string]string{}
+	if queriesFile != "" {
+		data, err := os.ReadFile(queriesFile)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &queries); err != nil {
+			return fmt.Errorf("%s: %w", queriesFile, err)
+		}
+	}
+	var names []string
+	if typeNames != "" {
+		names = strings.Split(typeNames, ",")
+	}
+
+	fset := token.NewFileSet()
+	pkgName, files, err := parsePackage(fset, dir, output)
+	if err != nil {
+		return err
+	}
+	g := newGenerator(fset, queries)
+	ifaces, err := g.findInterfaces(files, names)
+	if err != nil {
+		return err
+	}
+	src, err := g.generate(pkgName, ifaces)
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		output = filepath.Join(dir, strings.ToLower(ifaces[0].Name)+"_proteus.go")
+	}
+	return os.WriteFile(output, src, 0644)
+}
+
+// parsePackage parses the non-test Go files in dir, skipping the output file so that an old copy isn't read.
+func parsePackage(fset *token.FileSet, dir, output string) (string, []*ast.File, error) {
+	skip := filepath.Base(output)
+	pkgs, err := parser.ParseDir(fset, dir, func(fi fs.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go") && fi.Name() != skip
+	}, parser.ParseComments)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(pkgs) != 1 {
+		return "", nil, fmt.Errorf("expected one package in %s, found %d", dir, len(pkgs))
+	}
+	for name, pkg := range pkgs {
+		var fileNames []string
+		for k := range pkg.Files {
+			fileNames = append(fileNames, k)
+		}
+		sort.Strings(fileNames)
+		files := make([]*ast.File, len(fileNames))
+		for k, v := range fileNames {
+			files[k] = pkg.Files[v]
+		}
+		return name, files, nil
+	}
+	return "", nil, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/lib/pq"
+)
+
+// encodeValue returns the value bound for a parameter or tuple field with the given tag.
+// With the json option, the value is marshaled and bound as a string, so it can go into a json or jsonb column.
+// With the encrypted option, the value (after marshaling, if it is json) is encrypted with the current key.
+// A nil pointer, map, or slice is bound as NULL. A value that isn't allowed for its enum type is an error.
+func encodeValue(val reflect.Value, tag profTag, keys KeyProvider) (interface{}, error) {
+	if err := checkEnum(reflect.Indirect(val), tag.name); err != nil {
+		return nil, err
+	}
+	if !tag.has("json") && !tag.has("encrypted") {
+		return val.Interface(), nil
+	}
+	switch val.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		if val.IsNil() {
+			return nil, nil
+		}
+	}
+	if tag.has("json") {
+		b, err := json.Marshal(val.Interface())
+		if err != nil {
+			return nil, fmt.Errorf("unable to marshal %s to JSON: %w", tag.name, err)
+		}
+		val = reflect.ValueOf(string(b))
+	}
+	if !tag.has("encrypted") {
+		return val.Interface(), nil
+	}
+	val = reflect.Indirect(val)
+	var plaintext []byte
+	switch {
+	case val.Kind() == reflect.String:
+		plaintext = []byte(val.String())
+	case val.Kind() == reflect.Slice && val.Type().Elem().Kind() == reflect.Uint8:
+		plaintext = val.Bytes()
+	default:
+		return nil, fmt.Errorf("unable to encrypt %s of type %v: encrypted values must be strings or []byte", tag.name, val.Type())
+	}
+	out, err := encrypt(keys, plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encrypt %s: %w", tag.name, err)
+	}
+	return out, nil
+}
+
+// encodeArray returns a slice param bound as a single Postgres array, with each element encoded by encodeValue.
+// The slice is passed to pq.Array as it is, unless the elements are encrypted.
+func encodeArray(val reflect.Value, tag profTag, keys KeyProvider) (interface{}, error) {
+	if !tag.has("encrypted") {
+		for i := 0; i < val.Len(); i++ {
+			if err := checkEnum(reflect.Indirect(val.Index(i)), tag.name); err != nil {
+				return nil, err
+			}
+		}
+		return pq.Array(val.Interface()), nil
+	}
+	out := make([]interface{}, val.Len())
+	for i := range out {
+		arg, err := encodeValue(val.Index(i), tag, keys)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = arg
+	}
+	return pq.Array(out), nil
+}
+
+// decryptValue decrypts an encrypted column, returning the plaintext bytes.
+func decryptValue(src interface{}, sf *fieldInfo) (interface{}, error) {
+	var b []byte
+	switch v := src.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return nil, fmt.Errorf("Unable to decrypt value of type %T into struct field %s: encrypted columns must be strings or bytes", src, sf.name)
+	}
+	out, err := decrypt(sf.keys, b)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to decrypt value into struct field %s: %w", sf.name, err)
+	}
+	return out, nil
+}
+
+// decodeJSON unmarshals a json column into a struct field.
+func decodeJSON(field reflect.Value, src interface{}, sf *fieldInfo) error {
+	var b []byte
+	switch v := src.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("Unable to unmarshal value %v of type %T into struct field %s: json columns must be strings or bytes", src, src, sf.name)
+	}
+	//unmarshal into a new value, so a partial result isn't left behind in the field
+	out := reflect.New(sf.fieldType)
+	if err := json.Unmarshal(b, out.Interface()); err != nil {
+		return fmt.Errorf("Unable to unmarshal JSON into struct field %s: %w", sf.name, err)
+	}
+	field.Set(out.Elem())
+	return nil
+}
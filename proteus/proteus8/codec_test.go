@@ -0,0 +1,87 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+type settings struct {
+	Theme  string `json:"theme"`
+	Alerts bool   `json:"alerts"`
+}
+
+type settingsPerson struct {
+	Id       int       `prof:"id"`
+	Settings settings  `prof:"settings,json"`
+	Tags     []string  `prof:"tags,json"`
+	Extra    *settings `prof:"extra,json"`
+}
+
+func TestJSONColumns(t *testing.T) {
+	var dao struct {
+		Get    func(q Querier, id int) (*settingsPerson, error) `proq:"SELECT * FROM PERSON WHERE id = :id:" prop:"id"`
+		GetAll func(q Querier) ([]settingsPerson, error)        `proq:"SELECT * FROM PERSON"`
+	}
+	if err := Build(&dao, Postgres); err != nil {
+		t.Fatal(err)
+	}
+	fw := &fakeWrapper{
+		cols: []string{"id", "settings", "tags", "extra"},
+		rows: [][]interface{}{{int64(1), []byte(`{"theme":"dark","alerts":true}`), `["a","b"]`, nil}},
+	}
+	expected := settingsPerson{Id: 1, Settings: settings{Theme: "dark", Alerts: true}, Tags: []string{"a", "b"}}
+	person, err := dao.Get(fw, 1)
+	if err != nil || !reflect.DeepEqual(*person, expected) {
+		t.Errorf("expected %+v, got %+v, %v", expected, person, err)
+	}
+	people, err := dao.GetAll(fw)
+	if err != nil || len(people) != 1 || !reflect.DeepEqual(people[0], expected) {
+		t.Errorf("expected %+v, got %+v, %v", expected, people, err)
+	}
+
+	fw.rows = [][]interface{}{{int64(1), []byte(`{"theme":`), nil, nil}}
+	if _, err := dao.Get(fw, 1); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+	fw.rows = [][]interface{}{{int64(1), int64(5), nil, nil}}
+	if _, err := dao.GetAll(fw); err == nil {
+		t.Error("expected an error for a json column that isn't a string")
+	}
+}
+
+func TestJSONParams(t *testing.T) {
+	var dao struct {
+		Update func(e Executor, id int, s settings, extra *settings) (int64, error) `proq:"UPDATE PERSON SET settings = :s:, extra = :extra: WHERE id = :id:" prop:"id,s:json,extra:json"`
+		Tags   func(e Executor, id int, tags []string) (int64, error)               `proq:"UPDATE PERSON SET tags = :tags: WHERE id = :id:" prop:"id,tags:json"`
+		Insert func(e Executor, people []settingsPerson) (int64, error)             `proq:"INSERT INTO PERSON(id, settings, tags, extra) VALUES :people:" prop:"people"`
+	}
+	if err := Build(&dao, Postgres); err != nil {
+		t.Fatal(err)
+	}
+	fw := &fakeWrapper{}
+	if _, err := dao.Update(fw, 1, settings{Theme: "dark"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dao.Tags(fw, 1, []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dao.Insert(fw, []settingsPerson{{Id: 2, Settings: settings{Alerts: true}, Extra: &settings{Theme: "light"}}}); err != nil {
+		t.Fatal(err)
+	}
+	expectedQueries := []string{
+		"UPDATE PERSON SET settings = $1, extra = $2 WHERE id = $3",
+		"UPDATE PERSON SET tags = $1 WHERE id = $2",
+		"INSERT INTO PERSON(id, settings, tags, extra) VALUES ($1, $2, $3, $4)",
+	}
+	expectedArgs := [][]interface{}{
+		{`{"theme":"dark","alerts":false}`, nil, 1},
+		{`["a","b"]`, 1},
+		{2, `{"theme":"","alerts":true}`, nil, `{"theme":"light","alerts":false}`},
+	}
+	if !reflect.DeepEqual(fw.queries, expectedQueries) {
+		t.Errorf("expected %q, got %q", expectedQueries, fw.queries)
+	}
+	if !reflect.DeepEqual(fw.args, expectedArgs) {
+		t.Errorf("expected %#v, got %#v", expectedArgs, fw.args)
+	}
+}
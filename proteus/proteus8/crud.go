@@ -0,0 +1,256 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Crud holds the basic operations on the table for T. NewCrud generates them from T's prof tags,
+// so there are no queries to write:
+//
+//	type Person struct {
+//		Id   int    `prof:"id,key,generated"`
+//		Name string `prof:"name"`
+//		Age  int    `prof:"age"`
+//	}
+//
+//	people, err := NewCrud[Person]("person", PostgresDialect)
+//
+// Fields with the key option make up the primary key; there can be more than one. A key field with
+// the generated option is left out of inserts and set from the database by Create.
+// The other tag options work as they do for Build: a version field is checked and incremented by Update,
+// audit fields are set by Create, Update, and Upsert, and WithSoftDelete applies to Delete, Get, and GetAll.
+type Crud[T any] struct {
+	// Create inserts the entity, and sets its generated key field, if there is one. It takes a Wrapper because
+	// with a Dialect that supports RETURNING, the generated key is read back through Query.
+	Create func(w Wrapper, entity *T) error
+	// Get returns the row whose key columns match the key values, in the order the key fields are declared,
+	// or nil if there isn't one.
+	Get    func(q Querier, key ...interface{}) (*T, error)
+	GetAll func(q Querier) ([]T, error)
+	// Update writes the fields that aren't part of the key to the row with the entity's key, and returns the
+	// number of rows affected.
+	Update func(e Executor, entity *T) (int64, error)
+	// Upsert inserts the entity, including its key, or updates the row that has its key already.
+	// It returns an error if the Dialect has no Upsert clause.
+	Upsert func(e Executor, entity *T) (int64, error)
+	// Delete deletes the row whose key columns match the key values, and returns the number of rows affected.
+	Delete func(e Executor, key ...interface{}) (int64, error)
+}
+
+// NewCrud generates the operations on table for T. The options are the ones that can be passed to Build;
+// with a Dialect that supports RETURNING, WithReturning is on, and WithMaxParams is set to the Dialect's MaxParams.
+func NewCrud[T any](table string, dialect Dialect, options ...Option) (*Crud[T], error) {
+	entityType := reflect.TypeOf((*T)(nil)).Elem()
+	if !isCompositeStruct(entityType) {
+		return nil, fmt.Errorf("NewCrud needs a struct with prof tags, not %v", entityType)
+	}
+	opts := makeBuildOptions(dialect.options(options))
+
+	var all, keys, inserts, updates []structColumn
+	var generated *structColumn
+	for _, v := range structColumns(entityType, opts) {
+		v := v
+		all = append(all, v)
+		tag := v.info.tag
+		if tag.has("key") {
+			keys = append(keys, v)
+		} else if !tag.has("version") && !tag.has("created") {
+			updates = append(updates, v)
+		}
+		if tag.has("generated") {
+			if generated != nil {
+				return nil, fmt.Errorf("%v can only have one generated field", entityType)
+			}
+			if !tag.has("key") || !isIntKind(v.info.fieldType.Kind()) {
+				return nil, fmt.Errorf("generated field %s of %v must be an integer key", v.info.name, entityType)
+			}
+			generated = &v
+			continue
+		}
+		inserts = append(inserts, v)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%v needs at least one field with the key option", entityType)
+	}
+
+	var c Crud[T]
+	var create func(Wrapper, *T) (int64, error)
+	var update, upsert func(Executor, *T) (int64, error)
+	var del func(Executor, ...interface{}) (int64, error)
+
+	createOpts := opts
+	if generated != nil {
+		createOpts.returnCol = generated.name
+	}
+	createQuery, createParams := insertQuery(table, inserts, opts)
+	if err := buildCrudFunc(&create, createQuery, createParams, dialect, createOpts); err != nil {
+		return nil, err
+	}
+	c.Create = func(w Wrapper, entity *T) error {
+		if entity == nil {
+			return errNilEntity
+		}
+		id, err := create(w, entity)
+		if err != nil {
+			return err
+		}
+		if generated != nil {
+			reflect.ValueOf(entity).Elem().FieldByIndex(generated.info.index).SetInt(id)
+		}
+		return nil
+	}
+
+	keyWhere, keyParams := keyCondition(keys, opts)
+	if err := buildCrudFunc(&c.Get, fmt.Sprintf("SELECT %s FROM %s WHERE %s", columnList(all), table, keyWhere), keyParams, dialect, opts); err != nil {
+		return nil, err
+	}
+	if err := buildCrudFunc(&c.GetAll, fmt.Sprintf("SELECT %s FROM %s", columnList(all), table), nil, dialect, opts); err != nil {
+		return nil, err
+	}
+
+	sets := make([]string, len(updates))
+	updateParams := map[string]derivedValue{}
+	for k, v := range updates {
+		name := fmt.Sprintf("set%d", k)
+		sets[k] = fmt.Sprintf("%s = :%s:", v.name, name)
+		updateParams[name] = entityField(1, v, opts.keys)
+	}
+	var where []string
+	for k, v := range keys {
+		name := fmt.Sprintf("key%d", k)
+		where = append(where, fmt.Sprintf("%s = :%s:", v.name, name))
+		updateParams[name] = entityField(1, v, opts.keys)
+	}
+	if len(updates) > 0 {
+		updateQuery := fmt.Sprintf("UPDATE %s SET %s WHERE %s", table, strings.Join(sets, ", "), strings.Join(where, " AND "))
+		if err := buildCrudFunc(&update, updateQuery, updateParams, dialect, opts); err != nil {
+			return nil, err
+		}
+	}
+	c.Update = func(e Executor, entity *T) (int64, error) {
+		if entity == nil {
+			return 0, errNilEntity
+		}
+		if update == nil {
+			return 0, fmt.Errorf("%v has no fields to update", entityType)
+		}
+		return update(e, entity)
+	}
+
+	c.Upsert = func(e Executor, entity *T) (int64, error) {
+		return 0, errors.New("upsert isn't supported by this Dialect")
+	}
+	if dialect.Upsert != nil {
+		//the key is always inserted, even if it's generated
+		upsertQuery, upsertParams := insertQuery(table, dedupeColumns(append(append([]structColumn{}, keys...), inserts...)), opts)
+		upsertQuery += " " + dialect.Upsert(columnNames(keys), columnNames(updates))
+		if err := buildCrudFunc(&upsert, upsertQuery, upsertParams, dialect, opts); err != nil {
+			return nil, err
+		}
+		c.Upsert = func(e Executor, entity *T) (int64, error) {
+			if entity == nil {
+				return 0, errNilEntity
+			}
+			return upsert(e, entity)
+		}
+	}
+
+	if err := buildCrudFunc(&del, fmt.Sprintf("DELETE FROM %s WHERE %s", table, keyWhere), keyParams, dialect, opts); err != nil {
+		return nil, err
+	}
+	c.Delete = del
+	return &c, nil
+}
+
+var errNilEntity = errors.New("entity is nil")
+
+func columnNames(cols []structColumn) []string {
+	out := make([]string, len(cols))
+	for k, v := range cols {
+		out[k] = v.name
+	}
+	return out
+}
+
+func columnList(cols []structColumn) string {
+	return strings.Join(columnNames(cols), ", ")
+}
+
+func dedupeColumns(cols []structColumn) []structColumn {
+	seen := map[string]bool{}
+	var out []structColumn
+	for _, v := range cols {
+		if !seen[v.name] {
+			seen[v.name] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// insertQuery returns an INSERT of cols, with their values taken from the entity in the first param.
+func insertQuery(table string, cols []structColumn, opts buildOptions) (string, map[string]derivedValue) {
+	names := make([]string, len(cols))
+	params := map[string]derivedValue{}
+	for k, v := range cols {
+		name := fmt.Sprintf("col%d", k)
+		names[k] = ":" + name + ":"
+		params[name] = entityField(1, v, opts.keys)
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, columnList(cols), strings.Join(names, ", ")), params
+}
+
+// keyCondition returns a WHERE condition for the key columns, with their values taken from the variadic param.
+func keyCondition(keys []structColumn, opts buildOptions) (string, map[string]derivedValue) {
+	conds := make([]string, len(keys))
+	params := map[string]derivedValue{}
+	for k, v := range keys {
+		name := fmt.Sprintf("key%d", k)
+		conds[k] = fmt.Sprintf("%s = :%s:", v.name, name)
+		params[name] = keyValue(k, len(keys), v, opts.keys)
+	}
+	return strings.Join(conds, " AND "), params
+}
+
+// entityField returns the value of the column's field in the struct, or pointer to struct, at pos.
+func entityField(pos int, col structColumn, keys KeyProvider) derivedValue {
+	tag := profTag{name: col.name, options: col.info.tag.options}
+	return func(args []reflect.Value) (interface{}, error) {
+		val := fieldByIndexNil(args[pos], col.info.index)
+		if !val.IsValid() {
+			return nil, nil
+		}
+		return encodeValue(val, tag, keys)
+	}
+}
+
+// keyValue returns the pos'th of the count values in the variadic param.
+func keyValue(pos int, count int, col structColumn, keys KeyProvider) derivedValue {
+	tag := profTag{name: col.name, options: col.info.tag.options}
+	return func(args []reflect.Value) (interface{}, error) {
+		values := args[len(args)-1]
+		if values.Len() != count {
+			return nil, fmt.Errorf("expected %d key values, got %d", count, values.Len())
+		}
+		val := values.Index(pos).Elem()
+		if !val.IsValid() {
+			return nil, nil
+		}
+		return encodeValue(val, tag, keys)
+	}
+}
+
+// buildCrudFunc implements the func pointed to by target, as Build does for a DAO field.
+func buildCrudFunc(target interface{}, query string, params map[string]derivedValue, dialect Dialect, opts buildOptions) error {
+	fieldValue := reflect.ValueOf(target).Elem()
+	opts.derived = params
+	implementation, err := makeImplementation(fieldValue.Type(), query, dialect.Params, nil, opts)
+	if err != nil {
+		return err
+	}
+	fieldValue.Set(reflect.MakeFunc(fieldValue.Type(), implementation))
+	return nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type crudPerson struct {
+	Id        int       `prof:"id,key,generated"`
+	Name      string    `prof:"name"`
+	Age       int       `prof:"age"`
+	Version   int       `prof:"version,version"`
+	UpdatedAt time.Time `prof:"updated_at,updated"`
+}
+
+type membership struct {
+	GroupId  int    `prof:"group_id,key"`
+	PersonId int    `prof:"person_id,key"`
+	Role     string `prof:"role"`
+}
+
+func TestCrud(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	people, err := NewCrud[crudPerson]("person", PostgresDialect, WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw := &fakeWrapper{cols: []string{"id"}, rows: [][]interface{}{{int64(7)}}, result: fakeResult{rowsAffected: 1}}
+	p := &crudPerson{Name: "fred", Age: 20, Version: 1}
+	if err := people.Create(fw, p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Id != 7 || !p.UpdatedAt.Equal(now) {
+		t.Errorf("expected the generated id and updated time to be set, got %+v", p)
+	}
+	fw.cols = []string{"id", "name", "age", "version", "updated_at"}
+	fw.rows = [][]interface{}{{int64(7), "fred", int64(20), int64(1), now}}
+	got, err := people.Get(fw, 7)
+	if err != nil || !reflect.DeepEqual(got, p) {
+		t.Errorf("expected %+v, got %+v, %v", p, got, err)
+	}
+	all, err := people.GetAll(fw)
+	if err != nil || len(all) != 1 {
+		t.Errorf("unexpected people %+v, %v", all, err)
+	}
+	if _, err := people.Update(fw, p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Version != 2 {
+		t.Errorf("expected the version to be incremented, got %d", p.Version)
+	}
+	if _, err := people.Upsert(fw, p); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := people.Delete(fw, 7); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := people.Get(fw, 7, 8); err == nil {
+		t.Error("expected an error for the wrong number of key values")
+	}
+
+	expectedQueries := []string{
+		"INSERT INTO person (name, age, version, updated_at) VALUES ($1, $2, $3, $4) RETURNING id",
+		"SELECT id, name, age, version, updated_at FROM person WHERE id = $1",
+		"SELECT id, name, age, version, updated_at FROM person",
+		"UPDATE person SET version = version + 1, name = $1, age = $2, updated_at = $3 WHERE (id = $4) AND version = $5",
+		"INSERT INTO person (id, name, age, version, updated_at) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name, age = EXCLUDED.age, updated_at = EXCLUDED.updated_at",
+		"DELETE FROM person WHERE id = $1",
+	}
+	if !reflect.DeepEqual(fw.queries, expectedQueries) {
+		t.Errorf("expected %q, got %q", expectedQueries, fw.queries)
+	}
+	expectedArgs := [][]interface{}{
+		{"fred", 20, 1, now},
+		{7},
+		{},
+		{"fred", 20, now, 7, 1},
+		{7, "fred", 20, 2, now},
+		{7},
+	}
+	if !reflect.DeepEqual(fw.args, expectedArgs) {
+		t.Errorf("expected %v, got %v", expectedArgs, fw.args)
+	}
+	if err := people.Create(fw, nil); !errors.Is(err, errNilEntity) {
+		t.Errorf("expected an error for a nil entity, got %v", err)
+	}
+}
+
+func TestCrudCompositeKey(t *testing.T) {
+	members, err := NewCrud[membership]("membership", MySQLDialect, WithSoftDelete("deleted_at"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw := &fakeWrapper{cols: []string{"group_id", "person_id", "role"}}
+	m := &membership{GroupId: 1, PersonId: 2, Role: "admin"}
+	if err := members.Create(fw, m); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := members.Get(fw, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := members.Update(fw, m); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := members.Upsert(fw, m); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := members.Delete(fw, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	expectedQueries := []string{
+		"INSERT INTO membership (group_id, person_id, role) VALUES (?, ?, ?)",
+		"SELECT group_id, person_id, role FROM membership WHERE (group_id = ? AND person_id = ?) AND deleted_at IS NULL",
+		"UPDATE membership SET role = ? WHERE group_id = ? AND person_id = ?",
+		"INSERT INTO membership (group_id, person_id, role) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE role = VALUES(role)",
+		"UPDATE membership SET deleted_at = ? WHERE (group_id = ? AND person_id = ?) AND deleted_at IS NULL",
+	}
+	if !reflect.DeepEqual(fw.queries, expectedQueries) {
+		t.Errorf("expected %q, got %q", expectedQueries, fw.queries)
+	}
+}
+
+func TestCrudErrors(t *testing.T) {
+	if _, err := NewCrud[Person]("person", PostgresDialect); err == nil {
+		t.Error("expected an error for a struct without a key")
+	}
+	if _, err := NewCrud[int]("person", PostgresDialect); err == nil {
+		t.Error("expected an error for a type that isn't a struct")
+	}
+	members, err := NewCrud[membership]("membership", OracleDialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := members.Upsert(&fakeWrapper{}, &membership{}); err == nil {
+		t.Error("expected an error for a dialect without upsert")
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dialect holds what generated queries need to know about a database, beyond how it writes placeholders.
+type Dialect struct {
+	// Params writes the placeholders.
+	Params ParamAdapter
+	// Returning is true if the database supports INSERT ... RETURNING. See WithReturning.
+	Returning bool
+	// MaxParams is the most bind parameters a single statement can use. Calls whose slice parameters
+	// expand past it are split, as with WithMaxParams.
+	MaxParams int
+	// Upsert returns the clause that makes an INSERT update the existing row when the key columns match one,
+	// setting the update columns to the values that were inserted. It is nil if the database has no such clause.
+	Upsert func(keyCols, updateCols []string) string
+}
+
+var (
+	PostgresDialect = Dialect{Params: Postgres, Returning: true, MaxParams: PostgresMaxParams, Upsert: onConflictUpsert}
+	SqliteDialect   = Dialect{Params: Sqlite, MaxParams: SqliteMaxParams, Upsert: onConflictUpsert}
+	MySQLDialect    = Dialect{Params: MySQL, MaxParams: MySQLMaxParams, Upsert: duplicateKeyUpsert}
+	OracleDialect   = Dialect{Params: Oracle, MaxParams: OracleMaxParams}
+)
+
+// options returns the Options that the dialect implies, followed by options, so they can be overridden.
+func (d Dialect) options(options []Option) []Option {
+	var out []Option
+	if d.Returning {
+		out = append(out, WithReturning())
+	}
+	if d.MaxParams > 0 {
+		out = append(out, WithMaxParams(d.MaxParams))
+	}
+	return append(out, options...)
+}
+
+func onConflictUpsert(keyCols, updateCols []string) string {
+	if len(updateCols) == 0 {
+		return fmt.Sprintf("ON CONFLICT (%s) DO NOTHING", strings.Join(keyCols, ", "))
+	}
+	sets := make([]string, len(updateCols))
+	for k, v := range updateCols {
+		sets[k] = fmt.Sprintf("%s = EXCLUDED.%s", v, v)
+	}
+	return fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET %s", strings.Join(keyCols, ", "), strings.Join(sets, ", "))
+}
+
+func duplicateKeyUpsert(keyCols, updateCols []string) string {
+	if len(updateCols) == 0 {
+		//MySQL has no DO NOTHING; setting a key to itself leaves the row alone
+		updateCols = keyCols[:1]
+	}
+	sets := make([]string, len(updateCols))
+	for k, v := range updateCols {
+		sets[k] = fmt.Sprintf("%s = VALUES(%s)", v, v)
+	}
+	return "ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// emptyListKeywords are the words that can come right before the left side of an IN predicate.
+var emptyListKeywords = map[string]bool{"WHERE": true, "AND": true, "OR": true, "NOT": true, "ON": true, "HAVING": true, "WHEN": true}
+
+// inPredicate finds the IN or NOT IN predicate around a slice param, for EmptySliceFalse. before is the
+// template written so far and after is the text that follows the param. It returns where the predicate
+// starts in before, where it ends in after, and the condition that replaces it when the slice is empty:
+// 1=0 for IN, which matches no rows, and 1=1 for NOT IN, which matches every row.
+// ok is false if the param isn't the whole list of a predicate whose left side can be found; that is
+// an error for NOT IN, where leaving NULL in the list would match no rows instead of every row.
+func inPredicate(before, after string) (start, end int, cond string, ok bool, err error) {
+	pos := skipSpaceBack(before, len(before))
+	if pos == 0 || before[pos-1] != '(' {
+		return 0, 0, "", false, nil
+	}
+	pos = skipSpaceBack(before, pos-1)
+	word, wordStart := wordBefore(before, pos)
+	if !strings.EqualFold(word, "IN") {
+		return 0, 0, "", false, nil
+	}
+	cond = "1=0"
+	pos = skipSpaceBack(before, wordStart)
+	if word, wordStart := wordBefore(before, pos); strings.EqualFold(word, "NOT") {
+		cond = "1=1"
+		pos = skipSpaceBack(before, wordStart)
+	}
+
+	closeParen := strings.IndexFunc(after, func(r rune) bool { return !unicode.IsSpace(r) })
+	start = operandStart(before, pos)
+	if start == -1 || closeParen == -1 || after[closeParen] != ')' {
+		if cond == "1=1" {
+			return 0, 0, "", false, fmt.Errorf("can't find the NOT IN predicate to replace for an empty slice in %q", before+after)
+		}
+		return 0, 0, "", false, nil
+	}
+	return start, closeParen + 1, cond, true, nil
+}
+
+// operandStart returns the start of the expression that ends at end: a name, possibly qualified or quoted,
+// a function call, or a parenthesized list. It returns -1 if what comes before the expression isn't the
+// start of the query, an open parenthesis, a comma, or one of the emptyListKeywords, or if the expression
+// holds another param, since leaving that param out of the query would leave its arg bound.
+func operandStart(s string, end int) int {
+	pos := end
+scan:
+	for pos > 0 {
+		c := s[pos-1]
+		switch {
+		case c == ')':
+			depth := 0
+			for pos > 0 {
+				pos--
+				if s[pos] == ')' {
+					depth++
+				} else if s[pos] == '(' {
+					depth--
+					if depth == 0 {
+						break
+					}
+				}
+			}
+			if depth != 0 {
+				return -1
+			}
+		case c == '"' || c == '`':
+			open := strings.LastIndexByte(s[:pos-1], c)
+			if open == -1 {
+				return -1
+			}
+			pos = open
+		case c == '.' || c == '_' || c >= utf8.RuneSelf || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)):
+			pos--
+		default:
+			break scan
+		}
+	}
+	if pos == end || strings.Contains(s[pos:end], "{{") {
+		return -1
+	}
+	prev := skipSpaceBack(s, pos)
+	if prev == 0 || s[prev-1] == '(' || s[prev-1] == ',' {
+		return pos
+	}
+	if word, _ := wordBefore(s, prev); emptyListKeywords[strings.ToUpper(word)] {
+		return pos
+	}
+	return -1
+}
+
+func skipSpaceBack(s string, pos int) int {
+	for pos > 0 && unicode.IsSpace(rune(s[pos-1])) {
+		pos--
+	}
+	return pos
+}
+
+// wordBefore returns the word that ends at end, and where it starts.
+func wordBefore(s string, end int) (string, int) {
+	start := end
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(s[:start])
+		if !isIdentRune(r) {
+			break
+		}
+		start -= size
+	}
+	return s[start:end], start
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeyProvider supplies the AES keys for columns and params with the encrypted option.
+// Keys must be 16, 24, or 32 bytes long.
+//
+// Each value is stored as the id of the key that encrypted it, a colon, and the base64 encoded ciphertext.
+// To rotate keys, return a new id from CurrentKey and keep returning the old keys from Key until every
+// row has been rewritten.
+type KeyProvider interface {
+	// CurrentKey returns the key used to encrypt new values, and its id. The id can't contain a colon.
+	CurrentKey() (id string, key []byte, err error)
+	// Key returns the key with the given id, to decrypt values.
+	Key(id string) ([]byte, error)
+}
+
+// KeyRing is a KeyProvider that holds its keys in memory.
+type KeyRing struct {
+	// Current is the id of the key used to encrypt new values.
+	Current string
+	Keys    map[string][]byte
+}
+
+func (kr KeyRing) CurrentKey() (string, []byte, error) {
+	key, err := kr.Key(kr.Current)
+	return kr.Current, key, err
+}
+
+func (kr KeyRing) Key(id string) ([]byte, error) {
+	key, ok := kr.Keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+	return key, nil
+}
+
+// ErrUnknownKey is returned when a value was encrypted with a key that the KeyProvider doesn't have.
+var ErrUnknownKey = errors.New("unknown encryption key")
+
+// ErrNoKeyProvider is returned when a column or param is encrypted, but Build wasn't given a KeyProvider.
+var ErrNoKeyProvider = errors.New("encrypted value without a KeyProvider")
+
+// WithKeyProvider sets the KeyProvider used to encrypt params and decrypt columns with the encrypted option.
+// Params are encrypted with the prop tag option, as in prop:"id,ssn:encrypted", and struct fields with the
+// prof tag option, as in prof:"ssn,encrypted". Encrypted values must be strings or []byte, or use the json option too.
+func WithKeyProvider(keys KeyProvider) Option {
+	return func(o *buildOptions) {
+		o.keys = keys
+	}
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encrypt seals plaintext with the current key. The key id is authenticated along with the ciphertext,
+// so a value can't be passed off as one encrypted with a different key.
+func encrypt(keys KeyProvider, plaintext []byte) (string, error) {
+	if keys == nil {
+		return "", ErrNoKeyProvider
+	}
+	id, key, err := keys.CurrentKey()
+	if err != nil {
+		return "", err
+	}
+	if strings.Contains(id, ":") {
+		return "", fmt.Errorf("key id %q can't contain a colon", id)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, plaintext, []byte(id))
+	return id + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decrypt opens a value written by encrypt, using the key named by its prefix.
+func decrypt(keys KeyProvider, ciphertext []byte) ([]byte, error) {
+	if keys == nil {
+		return nil, ErrNoKeyProvider
+	}
+	id, encoded, ok := strings.Cut(string(ciphertext), ":")
+	if !ok {
+		return nil, errors.New("encrypted value has no key id")
+	}
+	key, err := keys.Key(id)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("encrypted value is too short")
+	}
+	return gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], []byte(id))
+}
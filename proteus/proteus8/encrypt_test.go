@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type secretPerson struct {
+	Id   int    `prof:"id"`
+	SSN  string `prof:"ssn,encrypted"`
+	Note []byte `prof:"note,encrypted"`
+}
+
+func TestEncryptedColumns(t *testing.T) {
+	keys := KeyRing{Current: "k1", Keys: map[string][]byte{
+		"k1": bytes.Repeat([]byte{1}, 32),
+		"k2": bytes.Repeat([]byte{2}, 16),
+	}}
+	var dao struct {
+		Insert func(e Executor, id int, ssn string) (int64, error)    `proq:"INSERT INTO PERSON(id, ssn) VALUES(:id:, :ssn:)" prop:"id,ssn:encrypted"`
+		Bulk   func(e Executor, people []secretPerson) (int64, error) `proq:"INSERT INTO PERSON(id, ssn, note) VALUES :people:" prop:"people"`
+		Get    func(q Querier, id int) (*secretPerson, error)         `proq:"SELECT * FROM PERSON WHERE id = :id:" prop:"id"`
+		GetAll func(q Querier) ([]secretPerson, error)                `proq:"SELECT * FROM PERSON"`
+	}
+	if err := Build(&dao, Postgres, WithKeyProvider(keys)); err != nil {
+		t.Fatal(err)
+	}
+	fw := &fakeWrapper{}
+	if _, err := dao.Insert(fw, 1, "123-45-6789"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dao.Bulk(fw, []secretPerson{{Id: 2, SSN: "987-65-4321", Note: []byte("hi")}}); err != nil {
+		t.Fatal(err)
+	}
+	ssn1 := fw.args[0][1].(string)
+	ssn2, note2 := fw.args[1][1].(string), fw.args[1][2].(string)
+	if !strings.HasPrefix(ssn1, "k1:") || strings.Contains(ssn1, "123-45-6789") || !strings.HasPrefix(ssn2, "k1:") {
+		t.Errorf("expected values encrypted with k1, got %q and %q", ssn1, ssn2)
+	}
+
+	//rotate the key; rows written with the old key still decrypt
+	keys.Current = "k2"
+	if err := Build(&dao, Postgres, WithKeyProvider(keys)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dao.Insert(fw, 3, "555-55-5555"); err != nil {
+		t.Fatal(err)
+	}
+	ssn3 := fw.args[2][1].(string)
+	if !strings.HasPrefix(ssn3, "k2:") {
+		t.Errorf("expected value encrypted with k2, got %q", ssn3)
+	}
+	fw.cols = []string{"id", "ssn", "note"}
+	fw.rows = [][]interface{}{{int64(2), []byte(ssn2), note2}, {int64(3), ssn3, nil}}
+	people, err := dao.GetAll(fw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(people) != 2 || people[0].SSN != "987-65-4321" || string(people[0].Note) != "hi" || people[1].SSN != "555-55-5555" || people[1].Note != nil {
+		t.Errorf("unexpected people %+v", people)
+	}
+	person, err := dao.Get(fw, 2)
+	if err != nil || person.SSN != "987-65-4321" {
+		t.Errorf("unexpected person %+v, %v", person, err)
+	}
+
+	//a value encrypted with a retired key can't be read
+	delete(keys.Keys, "k1")
+	fw.rows = [][]interface{}{{int64(1), ssn1, nil}}
+	if _, err := dao.Get(fw, 1); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected ErrUnknownKey, got %v", err)
+	}
+	//tampering with the key id is detected
+	fw.rows = [][]interface{}{{int64(3), "k2" + ssn2[2:], nil}}
+	if _, err := dao.GetAll(fw); err == nil {
+		t.Error("expected an error for a value with the wrong key id")
+	}
+}
+
+func TestEncryptedWithoutKeyProvider(t *testing.T) {
+	var dao struct {
+		Insert func(e Executor, ssn string) (int64, error) `proq:"INSERT INTO PERSON(ssn) VALUES(:ssn:)" prop:"ssn:encrypted"`
+	}
+	if err := Build(&dao, Postgres); !errors.Is(err, ErrNoKeyProvider) {
+		t.Errorf("expected ErrNoKeyProvider, got %v", err)
+	}
+	var dao2 struct {
+		Get func(q Querier) (*secretPerson, error) `proq:"SELECT * FROM PERSON"`
+	}
+	if err := Build(&dao2, Postgres); err != nil {
+		t.Fatal(err)
+	}
+	fw := &fakeWrapper{cols: []string{"id", "ssn"}, rows: [][]interface{}{{int64(1), "k1:abc"}}}
+	if _, err := dao2.Get(fw); !errors.Is(err, ErrNoKeyProvider) {
+		t.Errorf("expected ErrNoKeyProvider, got %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Enum is implemented by types that have a fixed set of values. Valid reports if the value is one of them.
+type Enum interface {
+	Valid() bool
+}
+
+// ErrInvalidEnum is returned when a column or param holds a value that isn't allowed for its enum type.
+var ErrInvalidEnum = errors.New("invalid enum value")
+
+var enumType = reflect.TypeOf((*Enum)(nil)).Elem()
+
+// enumValues holds the allowed values for each type passed to RegisterEnum.
+var enumValues sync.Map
+
+// RegisterEnum sets the allowed values for T, for enum types that don't implement Enum:
+//
+//	type Status string
+//
+//	func init() {
+//		RegisterEnum(Active, Suspended, Closed)
+//	}
+//
+// Once registered, mapping a column with any other value into a field of type T returns an error,
+// and so does calling a DAO func with any other value in a param of type T.
+// Calling RegisterEnum again for the same type replaces its values. Struct fields are only checked
+// if their type was registered before Build was called, so call it from an init func.
+func RegisterEnum[T comparable](values ...T) {
+	allowed := make(map[interface{}]bool, len(values))
+	for _, v := range values {
+		allowed[v] = true
+	}
+	enumValues.Store(reflect.TypeOf((*T)(nil)).Elem(), allowed)
+}
+
+// isEnum reports if t implements Enum or was passed to RegisterEnum.
+func isEnum(t reflect.Type) bool {
+	if t.Implements(enumType) {
+		return true
+	}
+	_, ok := enumValues.Load(t)
+	return ok
+}
+
+// checkEnum returns an error if val is of an enum type and isn't one of its values.
+func checkEnum(val reflect.Value, name string) error {
+	switch val.Kind() {
+	case reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		return nil
+	}
+	t := val.Type()
+	if t.Implements(enumType) {
+		if !val.Interface().(Enum).Valid() {
+			return fmt.Errorf("%w: %v is not a valid %v for %s", ErrInvalidEnum, val, t, name)
+		}
+		return nil
+	}
+	if allowed, ok := enumValues.Load(t); ok && !allowed.(map[interface{}]bool)[val.Interface()] {
+		return fmt.Errorf("%w: %v is not a valid %v for %s", ErrInvalidEnum, val, t, name)
+	}
+	return nil
+}
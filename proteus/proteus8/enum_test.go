@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+type status string
+
+const (
+	active    status = "active"
+	suspended status = "suspended"
+)
+
+func init() {
+	RegisterEnum(active, suspended)
+}
+
+type role int
+
+func (r role) Valid() bool {
+	return r >= 1 && r <= 3
+}
+
+type enumPerson struct {
+	Id     int    `prof:"id"`
+	Status status `prof:"status"`
+	Role   role   `prof:"role"`
+}
+
+func TestEnumColumns(t *testing.T) {
+	var dao struct {
+		Get    func(q Querier, id int) (*enumPerson, error) `proq:"SELECT * FROM PERSON WHERE id = :id:" prop:"id"`
+		GetAll func(q Querier) ([]enumPerson, error)        `proq:"SELECT * FROM PERSON"`
+	}
+	if err := Build(&dao, Postgres); err != nil {
+		t.Fatal(err)
+	}
+	fw := &fakeWrapper{cols: []string{"id", "status", "role"}, rows: [][]interface{}{{int64(1), []byte("active"), int64(2)}}}
+	person, err := dao.Get(fw, 1)
+	if err != nil || person.Status != active || person.Role != 2 {
+		t.Errorf("unexpected person %+v, %v", person, err)
+	}
+
+	fw.rows = [][]interface{}{{int64(1), "deleted", int64(2)}}
+	if _, err := dao.Get(fw, 1); !errors.Is(err, ErrInvalidEnum) {
+		t.Errorf("expected ErrInvalidEnum for an unregistered value, got %v", err)
+	}
+	fw.rows = [][]interface{}{{int64(1), "active", int64(7)}}
+	if _, err := dao.GetAll(fw); !errors.Is(err, ErrInvalidEnum) {
+		t.Errorf("expected ErrInvalidEnum for an invalid Enum, got %v", err)
+	}
+	//NULL leaves the zero value, which isn't checked
+	fw.rows = [][]interface{}{{int64(1), nil, nil}}
+	if _, err := dao.Get(fw, 1); err != nil {
+		t.Errorf("expected no error for NULL, got %v", err)
+	}
+}
+
+func TestEnumParams(t *testing.T) {
+	var dao struct {
+		SetStatus func(e Executor, id int, s status) (int64, error)    `proq:"UPDATE PERSON SET status = :s: WHERE id = :id:" prop:"id,s"`
+		ByRoles   func(q Querier, roles []role) ([]enumPerson, error)  `proq:"SELECT * FROM PERSON WHERE role IN (:roles:)" prop:"roles"`
+		Insert    func(e Executor, people []enumPerson) (int64, error) `proq:"INSERT INTO PERSON(id, status, role) VALUES :people:" prop:"people"`
+		SetPtr    func(e Executor, id int, s *status) (int64, error)   `proq:"UPDATE PERSON SET status = :s: WHERE id = :id:" prop:"id,s"`
+	}
+	if err := Build(&dao, Postgres); err != nil {
+		t.Fatal(err)
+	}
+	fw := &fakeWrapper{}
+	if _, err := dao.SetStatus(fw, 1, suspended); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dao.SetStatus(fw, 1, "bogus"); !errors.Is(err, ErrInvalidEnum) {
+		t.Errorf("expected ErrInvalidEnum, got %v", err)
+	}
+	if _, err := dao.ByRoles(fw, []role{1, 4}); !errors.Is(err, ErrInvalidEnum) {
+		t.Errorf("expected ErrInvalidEnum, got %v", err)
+	}
+	if _, err := dao.Insert(fw, []enumPerson{{Id: 1, Status: active, Role: 0}}); !errors.Is(err, ErrInvalidEnum) {
+		t.Errorf("expected ErrInvalidEnum, got %v", err)
+	}
+	bogus := status("bogus")
+	if _, err := dao.SetPtr(fw, 1, &bogus); !errors.Is(err, ErrInvalidEnum) {
+		t.Errorf("expected ErrInvalidEnum, got %v", err)
+	}
+	if _, err := dao.SetPtr(fw, 1, nil); err != nil {
+		t.Errorf("expected nil to bind NULL, got %v", err)
+	}
+	if len(fw.queries) != 2 {
+		t.Errorf("expected invalid params to be rejected before running, got %q", fw.queries)
+	}
+}
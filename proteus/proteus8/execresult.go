@@ -0,0 +1,123 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+)
+
+var resultType = reflect.TypeOf((*sql.Result)(nil)).Elem()
+
+// execOutput turns the result of running an Executor func's query into the func's return values.
+// result is nil if the query didn't run.
+type execOutput func(result sql.Result, err error) []reflect.Value
+
+// buildExecOutput checks the return types of an Executor func. The supported forms are:
+//
+//	error
+//	(sql.Result, error)
+//	(int, error) or any other integer type, holding RowsAffected
+//	(bool, error), true if at least one row was affected
+//	(S, error), where the struct S has integer RowsAffected and LastInsertId fields
+func buildExecOutput(funcType reflect.Type) (execOutput, error) {
+	if funcType.NumOut() == 0 || funcType.Out(funcType.NumOut()-1) != errType {
+		return nil, fmt.Errorf("an Executor func must return an error as its last value, not %v", funcType)
+	}
+	if funcType.NumOut() == 1 {
+		return func(result sql.Result, err error) []reflect.Value {
+			return []reflect.Value{errValue(err)}
+		}, nil
+	}
+	if funcType.NumOut() != 2 {
+		return nil, fmt.Errorf("an Executor func must return an error or a value and an error, not %v", funcType)
+	}
+
+	firstResult := funcType.Out(0)
+	zeroVal := reflect.Zero(firstResult)
+	var convert func(result sql.Result) (reflect.Value, error)
+	switch {
+	case firstResult == resultType:
+		convert = func(result sql.Result) (reflect.Value, error) {
+			return reflect.ValueOf(&result).Elem(), nil
+		}
+	case isIntKind(firstResult.Kind()):
+		convert = func(result sql.Result) (reflect.Value, error) {
+			count, err := result.RowsAffected()
+			return reflect.ValueOf(count).Convert(firstResult), err
+		}
+	case firstResult.Kind() == reflect.Bool:
+		convert = func(result sql.Result) (reflect.Value, error) {
+			count, err := result.RowsAffected()
+			return reflect.ValueOf(count > 0).Convert(firstResult), err
+		}
+	case firstResult.Kind() == reflect.Struct:
+		rowsAffected, ok := firstResult.FieldByName("RowsAffected")
+		lastInsertId, ok2 := firstResult.FieldByName("LastInsertId")
+		if !ok || !ok2 || !isIntKind(rowsAffected.Type.Kind()) || !isIntKind(lastInsertId.Type.Kind()) || rowsAffected.PkgPath != "" || lastInsertId.PkgPath != "" {
+			return nil, fmt.Errorf("struct %v returned by an Executor func must have exported integer RowsAffected and LastInsertId fields", firstResult)
+		}
+		convert = func(result sql.Result) (reflect.Value, error) {
+			out := reflect.New(firstResult).Elem()
+			count, err := result.RowsAffected()
+			if err != nil {
+				return zeroVal, err
+			}
+			id, err := result.LastInsertId()
+			if err != nil {
+				return zeroVal, err
+			}
+			out.FieldByIndex(rowsAffected.Index).SetInt(count)
+			out.FieldByIndex(lastInsertId.Index).SetInt(id)
+			return out, nil
+		}
+	default:
+		return nil, fmt.Errorf("an Executor func can't return %v; use sql.Result, an integer, a bool, or a struct with RowsAffected and LastInsertId fields", firstResult)
+	}
+
+	return func(result sql.Result, err error) []reflect.Value {
+		if result == nil {
+			return []reflect.Value{zeroVal, errValue(err)}
+		}
+		out, convErr := convert(result)
+		if err == nil {
+			err = convErr
+		}
+		return []reflect.Value{out, errValue(err)}
+	}, nil
+}
+
+// chunkedResult combines the results from a call that was split into several queries.
+type chunkedResult struct {
+	rowsAffected int64
+	lastInsertId int64
+}
+
+func (cr chunkedResult) LastInsertId() (int64, error) {
+	return cr.lastInsertId, nil
+}
+
+func (cr chunkedResult) RowsAffected() (int64, error) {
+	return cr.rowsAffected, nil
+}
+
+// combineResults sums RowsAffected across the results and keeps the last LastInsertId.
+// A single result is returned as-is, and no results become nil.
+func combineResults(results []sql.Result) (sql.Result, error) {
+	switch len(results) {
+	case 0:
+		return nil, nil
+	case 1:
+		return results[0], nil
+	}
+	var out chunkedResult
+	for _, v := range results {
+		count, err := v.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		out.rowsAffected += count
+	}
+	//not every driver supports LastInsertId, so only report an error if it's asked for
+	out.lastInsertId, _ = results[len(results)-1].LastInsertId()
+	return out, nil
+}
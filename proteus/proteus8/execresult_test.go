@@ -0,0 +1,79 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+)
+
+type execCounts struct {
+	RowsAffected int64
+	LastInsertId int
+}
+
+func TestExecutorReturnTypes(t *testing.T) {
+	var dao struct {
+		ErrOnly func(e Executor, id int) error                    `proq:"DELETE FROM PERSON WHERE id = :id:" prop:"id"`
+		Result  func(e Executor, id int) (sql.Result, error)      `proq:"DELETE FROM PERSON WHERE id = :id:" prop:"id"`
+		Int     func(e Executor, id int) (int, error)             `proq:"DELETE FROM PERSON WHERE id = :id:" prop:"id"`
+		Bool    func(e Executor, id int) (bool, error)            `proq:"DELETE FROM PERSON WHERE id = :id:" prop:"id"`
+		Struct  func(e Executor, name string) (execCounts, error) `proq:"INSERT INTO PERSON(name) VALUES(:name:)" prop:"name"`
+		Chunked func(e Executor, ids []int) (sql.Result, error)   `proq:"DELETE FROM PERSON WHERE id IN (:ids:)" prop:"ids"`
+	}
+	if err := Build(&dao, Postgres, WithMaxParams(2)); err != nil {
+		t.Fatal(err)
+	}
+
+	fw := &fakeWrapper{result: fakeResult{lastInsertId: 5, rowsAffected: 3}}
+	if err := dao.ErrOnly(fw, 1); err != nil {
+		t.Error(err)
+	}
+	if result, err := dao.Result(fw, 1); err != nil || result != fw.result {
+		t.Errorf("expected the driver's result, got %v, %v", result, err)
+	}
+	if count, err := dao.Int(fw, 1); err != nil || count != 3 {
+		t.Errorf("expected 3, got %v, %v", count, err)
+	}
+	if found, err := dao.Bool(fw, 1); err != nil || !found {
+		t.Errorf("expected true, got %v, %v", found, err)
+	}
+	if counts, err := dao.Struct(fw, "Fred"); err != nil || counts != (execCounts{RowsAffected: 3, LastInsertId: 5}) {
+		t.Errorf("expected both counts, got %v, %v", counts, err)
+	}
+	result, err := dao.Chunked(fw, []int{1, 2, 3, 4, 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := result.RowsAffected(); count != 9 {
+		t.Errorf("expected RowsAffected summed across 3 queries, got %d", count)
+	}
+
+	fw = &fakeWrapper{}
+	if found, err := dao.Bool(fw, 1); err != nil || found {
+		t.Errorf("expected false, got %v, %v", found, err)
+	}
+}
+
+func TestExecutorReturnTypesInvalid(t *testing.T) {
+	data := []interface{}{
+		&struct {
+			F func(e Executor, id int) string `proq:"DELETE FROM PERSON WHERE id = :id:" prop:"id"`
+		}{},
+		&struct {
+			F func(e Executor, id int) (string, error) `proq:"DELETE FROM PERSON WHERE id = :id:" prop:"id"`
+		}{},
+		&struct {
+			F func(e Executor, id int) (int, int, error) `proq:"DELETE FROM PERSON WHERE id = :id:" prop:"id"`
+		}{},
+		&struct {
+			F func(e Executor, id int) (Person, error) `proq:"DELETE FROM PERSON WHERE id = :id:" prop:"id"`
+		}{},
+		&struct {
+			F func(e Executor, id int) `proq:"DELETE FROM PERSON WHERE id = :id:" prop:"id"`
+		}{},
+	}
+	for _, v := range data {
+		if err := Build(v, Postgres); err == nil {
+			t.Errorf("expected %T to be rejected", v)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// fakeWrapper records the queries it is given and returns canned results, so the
+// DAO plumbing can be tested without a database.
+type fakeWrapper struct {
+	queries []string
+	args    [][]interface{}
+	cols    []string
+	rows    [][]interface{}
+	result  fakeResult
+}
+
+func (fw *fakeWrapper) Exec(query string, args ...interface{}) (sql.Result, error) {
+	fw.queries = append(fw.queries, query)
+	fw.args = append(fw.args, args)
+	return fw.result, nil
+}
+
+func (fw *fakeWrapper) Query(query string, args ...interface{}) (Rows, error) {
+	fw.queries = append(fw.queries, query)
+	fw.args = append(fw.args, args)
+	return &fakeRows{cols: fw.cols, rows: fw.rows, pos: -1}, nil
+}
+
+type fakeResult struct {
+	lastInsertId int64
+	rowsAffected int64
+}
+
+func (fr fakeResult) LastInsertId() (int64, error) {
+	return fr.lastInsertId, nil
+}
+
+func (fr fakeResult) RowsAffected() (int64, error) {
+	return fr.rowsAffected, nil
+}
+
+type fakeRows struct {
+	cols []string
+	rows [][]interface{}
+	pos  int
+}
+
+func (fr *fakeRows) Next() bool {
+	fr.pos++
+	return fr.pos < len(fr.rows)
+}
+
+func (fr *fakeRows) Err() error {
+	return nil
+}
+
+func (fr *fakeRows) Columns() ([]string, error) {
+	return fr.cols, nil
+}
+
+func (fr *fakeRows) Scan(dest ...interface{}) error {
+	row := fr.rows[fr.pos]
+	if len(dest) != len(row) {
+		return fmt.Errorf("expected %d destination arguments in Scan, not %d", len(row), len(dest))
+	}
+	for i, v := range row {
+		if p, ok := dest[i].(*interface{}); ok {
+			*p = v
+			continue
+		}
+		if s, ok := dest[i].(sql.Scanner); ok {
+			if err := s.Scan(v); err != nil {
+				return err
+			}
+			continue
+		}
+		d := reflect.ValueOf(dest[i])
+		if d.Kind() != reflect.Ptr || d.IsNil() {
+			return errors.New("destination not a pointer")
+		}
+		if v == nil {
+			d.Elem().Set(reflect.Zero(d.Elem().Type()))
+			continue
+		}
+		sv := reflect.ValueOf(v)
+		if !sv.Type().ConvertibleTo(d.Elem().Type()) {
+			return fmt.Errorf("can't scan %T into %s", v, d.Elem().Type())
+		}
+		d.Elem().Set(sv.Convert(d.Elem().Type()))
+	}
+	return nil
+}
+
+func (fr *fakeRows) Close() error {
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// BuildDAO returns a DAO of type T with its funcs implemented, as Build does. T must be a struct;
+// since it is returned by value, there's no pointer to pass or forget.
+//
+//	personDao, err := BuildDAO[PersonDao](Postgres)
+func BuildDAO[T any](paramAdapter ParamAdapter, options ...Option) (T, error) {
+	var dao T
+	if t := reflect.TypeOf(&dao).Elem(); t.Kind() != reflect.Struct {
+		return dao, fmt.Errorf("a DAO must be a struct, not %v", t)
+	}
+	if err := Build(&dao, paramAdapter, options...); err != nil {
+		var zero T
+		return zero, err
+	}
+	return dao, nil
+}
+
+// MustBuild is like BuildDAO, but panics if the DAO can't be built. It is meant for package-level variables:
+//
+//	var personDao = MustBuild[PersonDao](Postgres)
+func MustBuild[T any](paramAdapter ParamAdapter, options ...Option) T {
+	dao, err := BuildDAO[T](paramAdapter, options...)
+	if err != nil {
+		panic(err)
+	}
+	return dao
+}
+
+// BuildFunc returns a single func of type F that runs query, without a DAO struct. query and params are
+// what would go in the proq and prop tags of a DAO field.
+//
+//	getPerson, err := BuildFunc[func(q Querier, id int) (*Person, error)]("SELECT * FROM PERSON WHERE id = :id:", "id", Postgres)
+func BuildFunc[F any](query string, params string, paramAdapter ParamAdapter, options ...Option) (F, error) {
+	var f F
+	funcType := reflect.TypeOf(&f).Elem()
+	if funcType.Kind() != reflect.Func {
+		return f, fmt.Errorf("BuildFunc needs a func type, not %v", funcType)
+	}
+	opts := makeBuildOptions(options)
+	opts.paramTags = buildParamTags(params)
+	implementation, err := makeImplementation(funcType, query, paramAdapter, buildNameOrderMap(params), opts)
+	if err != nil {
+		return f, err
+	}
+	reflect.ValueOf(&f).Elem().Set(reflect.MakeFunc(funcType, implementation))
+	return f, nil
+}
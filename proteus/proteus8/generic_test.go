@@ -0,0 +1,66 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildDAO(t *testing.T) {
+	dao, err := BuildDAO[PersonDao](Postgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw := &fakeWrapper{cols: []string{"id", "name", "age"}, rows: [][]interface{}{{int64(1), "fred", int64(20)}}}
+	person, err := dao.Get(fw, 1)
+	if err != nil || !reflect.DeepEqual(*person, Person{Id: 1, Name: "fred", Age: 20}) {
+		t.Errorf("unexpected person %+v, %v", person, err)
+	}
+
+	if _, err := BuildDAO[int](Postgres); err == nil {
+		t.Error("expected an error for a DAO that isn't a struct")
+	}
+	type badDao struct {
+		Get func(id int) (*Person, error) `proq:"SELECT * FROM PERSON WHERE id = :id:" prop:"id"`
+	}
+	if _, err := BuildDAO[badDao](Postgres); err == nil {
+		t.Error("expected an error for a func without an Executor or Querier")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("expected MustBuild to panic")
+		}
+	}()
+	MustBuild[badDao](Postgres)
+}
+
+func TestBuildFunc(t *testing.T) {
+	getByAge, err := BuildFunc[func(q Querier, name string, ages []int) ([]Person, error)](
+		"SELECT * FROM PERSON WHERE name = :name: AND age IN (:ages:)", "name,ages", Postgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw := &fakeWrapper{cols: []string{"id", "name", "age"}, rows: [][]interface{}{{int64(1), "fred", int64(20)}}}
+	people, err := getByAge(fw, "fred", []int{20, 30})
+	if err != nil || len(people) != 1 {
+		t.Errorf("unexpected people %+v, %v", people, err)
+	}
+	if fw.queries[0] != "SELECT * FROM PERSON WHERE name = $1 AND age IN ($2, $3)" {
+		t.Errorf("unexpected query %q", fw.queries[0])
+	}
+
+	if _, err := BuildFunc[int]("SELECT 1", "", Postgres); err == nil {
+		t.Error("expected an error for a type that isn't a func")
+	}
+}
+
+func TestBuildFuncBadResult(t *testing.T) {
+	if _, err := BuildFunc[func(q Querier) ([]*Person, error)]("SELECT * FROM PERSON", "", Postgres); err == nil {
+		t.Error("expected an error for a slice of pointers")
+	}
+	if _, err := BuildFunc[func(q Querier) (Person, error)]("SELECT * FROM PERSON", "", Postgres); err == nil {
+		t.Error("expected an error for a struct result")
+	}
+	if _, err := BuildFunc[func(q Querier) *Person]("SELECT * FROM PERSON", "", Postgres); err == nil {
+		t.Error("expected an error for a func without an error result")
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"reflect"
+)
+
+// AfterLoader is implemented by structs that need to do work after they are filled from a row,
+// such as setting computed fields. If AfterLoad returns an error, the query returns it.
+type AfterLoader interface {
+	AfterLoad() error
+}
+
+// BeforeSaver is implemented by structs that need to do work before they are passed to an Executor func.
+type BeforeSaver interface {
+	BeforeSave() error
+}
+
+// Validator is implemented by structs that check their invariants before they are passed to an Executor func.
+// Validate is called after BeforeSave.
+type Validator interface {
+	Validate() error
+}
+
+var afterLoaderType = reflect.TypeOf((*AfterLoader)(nil)).Elem()
+var beforeSaverType = reflect.TypeOf((*BeforeSaver)(nil)).Elem()
+var validatorType = reflect.TypeOf((*Validator)(nil)).Elem()
+
+func hasAfterLoad(structType reflect.Type) bool {
+	return reflect.PtrTo(structType).Implements(afterLoaderType)
+}
+
+// runAfterLoad calls AfterLoad on val, which must be an addressable struct.
+func runAfterLoad(val reflect.Value) error {
+	if al, ok := val.Addr().Interface().(AfterLoader); ok {
+		return al.AfterLoad()
+	}
+	return nil
+}
+
+func hasSaveHooks(t reflect.Type) bool {
+	return t.Implements(beforeSaverType) || t.Implements(validatorType) ||
+		reflect.PtrTo(t).Implements(beforeSaverType) || reflect.PtrTo(t).Implements(validatorType)
+}
+
+// buildSaveHooks finds the parameters of an Executor func that are structs, pointers to structs,
+// or slices of either, with BeforeSave or Validate methods. It returns nil if there aren't any.
+// The returned func calls the hooks before the statement runs.
+func buildSaveHooks(funcType reflect.Type) func(args []reflect.Value) error {
+	positions := structParams(funcType, hasSaveHooks)
+	if len(positions) == 0 {
+		return nil
+	}
+	return func(args []reflect.Value) error {
+		return updateStructArgs(args, positions, runSaveHooks)
+	}
+}
+
+func runSaveHooks(val reflect.Value) error {
+	if bs, ok := val.Addr().Interface().(BeforeSaver); ok {
+		if err := bs.BeforeSave(); err != nil {
+			return err
+		}
+	}
+	if v, ok := val.Addr().Interface().(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// structParams returns the positions of the params of funcType that are structs, pointers to structs,
+// or slices of either, where the struct type matches.
+func structParams(funcType reflect.Type, matches func(reflect.Type) bool) []int {
+	var positions []int
+	for i := 1; i < funcType.NumIn(); i++ {
+		t := funcType.In(i)
+		if t.Kind() == reflect.Slice {
+			t = t.Elem()
+		}
+		if indirectType(t).Kind() == reflect.Struct && matches(indirectType(t)) {
+			positions = append(positions, i)
+		}
+	}
+	return positions
+}
+
+// updateStructArgs calls update on each struct in the args at positions, which come from structParams.
+// A struct passed by value, or a slice, is copied first, so that the caller's values aren't changed,
+// but the changes are what get bound. Structs passed by pointer are changed in place.
+func updateStructArgs(args []reflect.Value, positions []int, update func(reflect.Value) error) error {
+	for _, pos := range positions {
+		arg := args[pos]
+		if arg.Kind() != reflect.Slice {
+			updated, err := updateStructArg(arg, update)
+			if err != nil {
+				return err
+			}
+			args[pos] = updated
+			continue
+		}
+		out := reflect.MakeSlice(arg.Type(), arg.Len(), arg.Len())
+		for i := 0; i < arg.Len(); i++ {
+			updated, err := updateStructArg(arg.Index(i), update)
+			if err != nil {
+				return err
+			}
+			out.Index(i).Set(updated)
+		}
+		args[pos] = out
+	}
+	return nil
+}
+
+func updateStructArg(val reflect.Value, update func(reflect.Value) error) (reflect.Value, error) {
+	if val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return val, nil
+		}
+		return val, update(val.Elem())
+	}
+	target := reflect.New(val.Type()).Elem()
+	target.Set(val)
+	if err := update(target); err != nil {
+		return val, err
+	}
+	return target, nil
+}
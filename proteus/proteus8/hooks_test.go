@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type hookPerson struct {
+	Id    int    `prof:"id,key"`
+	Name  string `prof:"name"`
+	Upper string
+	Tags  []hookTag `prof:"tag_,many"`
+	Count int
+}
+
+func (hp *hookPerson) AfterLoad() error {
+	if hp.Name == "bad" {
+		return errors.New("bad name")
+	}
+	hp.Upper = strings.ToUpper(hp.Name)
+	hp.Count = len(hp.Tags)
+	return nil
+}
+
+type hookTag struct {
+	Name   string `prof:"name"`
+	Loaded bool
+}
+
+func (ht *hookTag) AfterLoad() error {
+	ht.Loaded = true
+	return nil
+}
+
+type savePerson struct {
+	Name string `prof:"name"`
+	Age  int    `prof:"age"`
+}
+
+func (sp *savePerson) BeforeSave() error {
+	sp.Name = strings.TrimSpace(sp.Name)
+	return nil
+}
+
+func (sp savePerson) Validate() error {
+	if sp.Name == "" {
+		return errors.New("name is required")
+	}
+	return nil
+}
+
+func TestAfterLoad(t *testing.T) {
+	var dao struct {
+		Get    func(q Querier, id int) (*hookPerson, error) `proq:"SELECT * FROM PERSON WHERE id = :id:" prop:"id"`
+		GetAll func(q Querier) ([]hookPerson, error)        `proq:"SELECT * FROM PERSON"`
+		ByID   func(q Querier) (map[int]hookPerson, error)  `proq:"SELECT * FROM PERSON" prokey:"id"`
+	}
+	if err := Build(&dao, Postgres); err != nil {
+		t.Fatal(err)
+	}
+	fw := &fakeWrapper{
+		cols: []string{"id", "name", "tag_name"},
+		rows: [][]interface{}{{int64(1), "fred", "a"}, {int64(1), "fred", "b"}, {int64(2), "bob", nil}},
+	}
+	people, err := dao.GetAll(fw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(people) != 2 || people[0].Upper != "FRED" || people[0].Count != 2 || !people[0].Tags[1].Loaded || people[1].Upper != "BOB" {
+		t.Errorf("expected AfterLoad to run once the rows were grouped, got %+v", people)
+	}
+
+	fw = &fakeWrapper{cols: []string{"id", "name"}, rows: [][]interface{}{{int64(1), "fred"}}}
+	person, err := dao.Get(fw, 1)
+	if err != nil || person.Upper != "FRED" {
+		t.Errorf("expected AfterLoad to run, got %+v, %v", person, err)
+	}
+	byID, err := dao.ByID(fw)
+	if err != nil || byID[1].Upper != "FRED" {
+		t.Errorf("expected AfterLoad to run, got %+v, %v", byID, err)
+	}
+
+	fw = &fakeWrapper{cols: []string{"id", "name"}, rows: [][]interface{}{{int64(1), "bad"}}}
+	if _, err := dao.Get(fw, 1); err == nil || err.Error() != "bad name" {
+		t.Errorf("expected the AfterLoad error, got %v", err)
+	}
+}
+
+func TestBeforeSave(t *testing.T) {
+	var dao struct {
+		Create    func(e Executor, p savePerson) error   `proq:"INSERT INTO PERSON(name) VALUES(:p:)" prop:"p"`
+		CreatePtr func(e Executor, p *savePerson) error  `proq:"INSERT INTO PERSON(name) VALUES(:p:)" prop:"p"`
+		CreateAll func(e Executor, p []savePerson) error `proq:"INSERT INTO PERSON(name, age) VALUES :p:" prop:"p"`
+	}
+	if err := Build(&dao, Postgres); err != nil {
+		t.Fatal(err)
+	}
+	fw := &fakeWrapper{}
+
+	p := savePerson{Name: "  Fred  "}
+	if err := dao.Create(fw, p); err != nil {
+		t.Fatal(err)
+	}
+	if bound := fw.args[0][0].(savePerson); bound.Name != "Fred" || p.Name != "  Fred  " {
+		t.Errorf("expected a trimmed copy to be bound, got %+v and %+v", bound, p)
+	}
+
+	if err := dao.CreatePtr(fw, &p); err != nil || p.Name != "Fred" {
+		t.Errorf("expected BeforeSave to change the struct, got %+v, %v", p, err)
+	}
+
+	all := []savePerson{{Name: " a ", Age: 1}, {Name: " b ", Age: 2}}
+	if err := dao.CreateAll(fw, all); err != nil {
+		t.Fatal(err)
+	}
+	if fw.args[2][0] != "a" || fw.args[2][2] != "b" || all[0].Name != " a " {
+		t.Errorf("expected each element to be saved, got %v", fw.args[2])
+	}
+
+	count := len(fw.queries)
+	if err := dao.Create(fw, savePerson{Name: "   "}); err == nil || err.Error() != "name is required" {
+		t.Errorf("expected the Validate error, got %v", err)
+	}
+	if len(fw.queries) != count {
+		t.Error("expected the statement not to run")
+	}
+}
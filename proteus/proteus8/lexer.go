@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	textToken tokenKind = iota
+	paramToken
+)
+
+// token is a piece of a query. Text tokens are passed through to the database unchanged,
+// param tokens hold the name found between a pair of colons.
+type token struct {
+	kind  tokenKind
+	value string
+}
+
+// lexQuery splits a proq query into text and parameter tokens.
+//
+// A parameter is written as :name:. Colons that appear inside string literals, quoted identifiers,
+// comments, and dollar-quoted bodies are left alone, as are :: casts. Any other colon can still be
+// passed through by escaping it with a backslash.
+func lexQuery(query string) ([]token, error) {
+	l := lexer{in: []rune(query)}
+	if err := l.run(); err != nil {
+		return nil, err
+	}
+	return l.out, nil
+}
+
+// maskQuery returns the runes of query with the string literals, quoted identifiers, dollar-quoted bodies,
+// escaped runes, and param names replaced by #, and comments replaced by spaces. What's left is the SQL itself,
+// at the same positions, so it can be searched for keywords without finding them inside a literal or a param
+// like :limit:.
+func maskQuery(query string) ([]rune, error) {
+	mask, _, err := maskTokens(query)
+	if err != nil {
+		return nil, err
+	}
+	return []rune(mask), nil
+}
+
+// maskTokens returns the mask of query as a string, along with the byte offset in the mask of each of the
+// tokens that lexQuery returns.
+func maskTokens(query string) (string, []int, error) {
+	l := lexer{in: []rune(query), mask: true}
+	if err := l.run(); err != nil {
+		return "", nil, err
+	}
+	var b bytes.Buffer
+	offsets := make([]int, len(l.out))
+	for k, v := range l.out {
+		offsets[k] = b.Len()
+		if v.kind == paramToken {
+			b.WriteString(":" + strings.Repeat("#", utf8.RuneCountInString(v.value)) + ":")
+			continue
+		}
+		b.WriteString(v.value)
+	}
+	return b.String(), offsets, nil
+}
+
+func (l *lexer) run() error {
+	for l.pos < len(l.in) {
+		start, textLen := l.pos, l.text.Len()
+		literal, comment := true, false
+		var err error
+		switch r := l.in[l.pos]; {
+		case r == '\\':
+			l.pos++
+			if l.pos < len(l.in) {
+				l.text.WriteRune(l.in[l.pos])
+				l.pos++
+			}
+		case r == '\'':
+			err = l.quoted('\'', l.isEString())
+		case r == '"' || r == '`':
+			err = l.quoted(r, false)
+		case r == '-' && l.peek(1) == '-':
+			comment = true
+			l.lineComment()
+		case r == '/' && l.peek(1) == '*':
+			comment = true
+			err = l.blockComment()
+		case r == '$':
+			err = l.dollar()
+		case r == ':' && l.peek(1) == ':':
+			literal = false
+			l.text.WriteString("::")
+			l.pos += 2
+		case r == ':':
+			literal = false
+			err = l.param()
+		default:
+			literal = false
+			l.text.WriteRune(r)
+			l.pos++
+		}
+		if err != nil {
+			return err
+		}
+		if l.mask && literal {
+			fill := "#"
+			if comment {
+				fill = " "
+			}
+			l.text.Truncate(textLen)
+			l.text.WriteString(strings.Repeat(fill, l.pos-start))
+		}
+	}
+	l.flush()
+	return nil
+}
+
+type lexer struct {
+	in   []rune
+	pos  int
+	text bytes.Buffer
+	out  []token
+	mask bool
+}
+
+func (l *lexer) peek(offset int) rune {
+	if l.pos+offset >= len(l.in) {
+		return 0
+	}
+	return l.in[l.pos+offset]
+}
+
+func (l *lexer) flush() {
+	if l.text.Len() > 0 {
+		l.out = append(l.out, token{kind: textToken, value: l.text.String()})
+		l.text.Reset()
+	}
+}
+
+// isEString reports if the quote at the current position starts a Postgres E'...' string,
+// where backslash escapes are interpreted by the database.
+func (l *lexer) isEString() bool {
+	if l.pos == 0 || (l.in[l.pos-1] != 'E' && l.in[l.pos-1] != 'e') {
+		return false
+	}
+	return l.pos == 1 || !isIdentRune(l.in[l.pos-2])
+}
+
+// quoted copies a string literal or quoted identifier. A doubled delimiter is an escaped delimiter.
+// A backslash followed by a colon is collapsed to a colon so that queries written for the old scanner
+// keep working; any other backslash is copied as-is, and escapes the next rune in an E string.
+func (l *lexer) quoted(delim rune, backslashEscapes bool) error {
+	start := l.pos
+	l.text.WriteRune(delim)
+	l.pos++
+	for l.pos < len(l.in) {
+		r := l.in[l.pos]
+		switch {
+		case r == '\\' && l.peek(1) == ':':
+			l.text.WriteRune(':')
+			l.pos += 2
+		case r == '\\' && backslashEscapes && l.pos+1 < len(l.in):
+			l.text.WriteRune(r)
+			l.text.WriteRune(l.in[l.pos+1])
+			l.pos += 2
+		case r == delim && l.peek(1) == delim:
+			l.text.WriteRune(r)
+			l.text.WriteRune(r)
+			l.pos += 2
+		case r == delim:
+			l.text.WriteRune(r)
+			l.pos++
+			return nil
+		default:
+			l.text.WriteRune(r)
+			l.pos++
+		}
+	}
+	return fmt.Errorf("unterminated %c quote starting at position %d", delim, start)
+}
+
+func (l *lexer) lineComment() {
+	for l.pos < len(l.in) {
+		r := l.in[l.pos]
+		l.text.WriteRune(r)
+		l.pos++
+		if r == '\n' {
+			return
+		}
+	}
+}
+
+// blockComment copies a /* */ comment. Comments nest, as they do in Postgres.
+func (l *lexer) blockComment() error {
+	start := l.pos
+	depth := 0
+	for l.pos < len(l.in) {
+		switch {
+		case l.in[l.pos] == '/' && l.peek(1) == '*':
+			depth++
+			l.text.WriteString("/*")
+			l.pos += 2
+		case l.in[l.pos] == '*' && l.peek(1) == '/':
+			depth--
+			l.text.WriteString("*/")
+			l.pos += 2
+			if depth == 0 {
+				return nil
+			}
+		default:
+			l.text.WriteRune(l.in[l.pos])
+			l.pos++
+		}
+	}
+	return fmt.Errorf("unterminated comment starting at position %d", start)
+}
+
+// dollar copies a $tag$...$tag$ body. A $ that doesn't start a dollar quote (such as a $1 placeholder)
+// is copied as-is.
+func (l *lexer) dollar() error {
+	start := l.pos
+	end := l.pos + 1
+	for end < len(l.in) && isIdentRune(l.in[end]) && !(end == l.pos+1 && unicode.IsDigit(l.in[end])) {
+		end++
+	}
+	if end >= len(l.in) || l.in[end] != '$' {
+		l.text.WriteRune('$')
+		l.pos++
+		return nil
+	}
+	tag := string(l.in[l.pos : end+1])
+	rest := string(l.in[end+1:])
+	closing := strings.Index(rest, tag)
+	if closing == -1 {
+		return fmt.Errorf("unterminated dollar-quoted string %s starting at position %d", tag, start)
+	}
+	body := tag + rest[:closing] + tag
+	l.text.WriteString(body)
+	l.pos += len([]rune(body))
+	return nil
+}
+
+func (l *lexer) param() error {
+	start := l.pos
+	l.pos++
+	var name bytes.Buffer
+	for l.pos < len(l.in) {
+		r := l.in[l.pos]
+		l.pos++
+		if r == ':' {
+			if name.Len() == 0 {
+				return fmt.Errorf("empty parameter name at position %d", start)
+			}
+			l.flush()
+			l.out = append(l.out, token{kind: paramToken, value: name.String()})
+			return nil
+		}
+		if !isIdentRune(r) {
+			return fmt.Errorf("invalid character %q in parameter name at position %d", r, l.pos-1)
+		}
+		name.WriteRune(r)
+	}
+	return fmt.Errorf("unterminated parameter starting at position %d", start)
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestLexQuery(t *testing.T) {
+	data := []struct {
+		query  string
+		tokens []token
+	}{
+		{"SELECT * FROM PERSON WHERE id = :id:", []token{{textToken, "SELECT * FROM PERSON WHERE id = "}, {paramToken, "id"}}},
+		{"SELECT created_at::date FROM PERSON WHERE id = :id:::int", []token{{textToken, "SELECT created_at::date FROM PERSON WHERE id = "}, {paramToken, "id"}, {textToken, "::int"}}},
+		{"SELECT * FROM PERSON WHERE opens = '10:30' AND name = :name:", []token{{textToken, "SELECT * FROM PERSON WHERE opens = '10:30' AND name = "}, {paramToken, "name"}}},
+		{"SELECT 'it''s :not: a param'", []token{{textToken, "SELECT 'it''s :not: a param'"}}},
+		{`SELECT E'it\'s :not: a param'`, []token{{textToken, `SELECT E'it\'s :not: a param'`}}},
+		{`SELECT '10\:30'`, []token{{textToken, "SELECT '10:30'"}}},
+		{`SELECT "odd:column" FROM PERSON`, []token{{textToken, `SELECT "odd:column" FROM PERSON`}}},
+		{"SELECT `odd:column` FROM PERSON", []token{{textToken, "SELECT `odd:column` FROM PERSON"}}},
+		{"SELECT 1 -- :nope:\nWHERE a = :a:", []token{{textToken, "SELECT 1 -- :nope:\nWHERE a = "}, {paramToken, "a"}}},
+		{"SELECT /* :nope: /* nested: */ */ :a:", []token{{textToken, "SELECT /* :nope: /* nested: */ */ "}, {paramToken, "a"}}},
+		{"DO $$ BEGIN PERFORM 'a:b'; END $$", []token{{textToken, "DO $$ BEGIN PERFORM 'a:b'; END $$"}}},
+		{"DO $fn$ :x: $fn$ :y:", []token{{textToken, "DO $fn$ :x: $fn$ "}, {paramToken, "y"}}},
+		{"SELECT $1, :a:", []token{{textToken, "SELECT $1, "}, {paramToken, "a"}}},
+		{`SELECT 10\:30`, []token{{textToken, "SELECT 10:30"}}},
+		{":a::b:", []token{{paramToken, "a"}, {paramToken, "b"}}},
+	}
+	for _, v := range data {
+		tokens, err := lexQuery(v.query)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", v.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(tokens, v.tokens) {
+			t.Errorf("%q: expected %v, got %v", v.query, v.tokens, tokens)
+		}
+	}
+}
+
+func TestLexQueryErrors(t *testing.T) {
+	data := []string{
+		"SELECT * FROM PERSON WHERE id = :id",
+		"SELECT * FROM PERSON WHERE id = :i d:",
+		"SELECT * FROM PERSON WHERE id = :-:",
+		"SELECT 'unterminated",
+		`SELECT "unterminated`,
+		"SELECT /* unterminated",
+		"DO $$ unterminated",
+	}
+	for _, v := range data {
+		if _, err := lexQuery(v); err == nil {
+			t.Errorf("%q: expected an error", v)
+		}
+	}
+}
+
+func FuzzLexQuery(f *testing.F) {
+	seeds := []string{
+		"SELECT * FROM PERSON WHERE id = :id:",
+		"SELECT created_at::date FROM PERSON WHERE id = :id:::int",
+		"SELECT * FROM PERSON WHERE opens = '10:30' AND name = :name:",
+		`SELECT E'it\'s' || "a:b" || $$c:d$$ -- :e:` + "\n/* :f: */ :g:",
+		"SELECT * from PERSON WHERE name=:name: and age in (:ages:) and id = :id:",
+	}
+	for _, v := range seeds {
+		f.Add(v)
+	}
+	f.Fuzz(func(t *testing.T, query string) {
+		if !utf8.ValidString(query) {
+			return
+		}
+		tokens, err := lexQuery(query)
+		if err != nil {
+			return
+		}
+		var rebuilt bytes.Buffer
+		for _, tok := range tokens {
+			switch tok.kind {
+			case textToken:
+				if tok.value == "" {
+					t.Fatalf("%q: empty text token", query)
+				}
+				rebuilt.WriteString(tok.value)
+			case paramToken:
+				if tok.value == "" || strings.IndexFunc(tok.value, func(r rune) bool { return !isIdentRune(r) }) != -1 {
+					t.Fatalf("%q: invalid parameter name %q", query, tok.value)
+				}
+				rebuilt.WriteString(":" + tok.value + ":")
+			}
+		}
+		//without escapes, the tokens put back together must be the original query
+		if !strings.Contains(query, `\`) && rebuilt.String() != query {
+			t.Fatalf("%q: rebuilt as %q", query, rebuilt.String())
+		}
+	})
+}
+
+func TestBuildFixedQueryKeepsLiterals(t *testing.T) {
+	funcType := reflect.TypeOf(func(q Querier, id int) (*Person, error) { return nil, nil })
+	query, _, err := buildFixedQueryAndParamOrder("SELECT '{{x}}', created_at::date FROM PERSON WHERE id = :id:", map[string]int{"id": 1}, funcType, Postgres, buildOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	finalQuery, err := query.finalize(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "SELECT '{{x}}', created_at::date FROM PERSON WHERE id = $1"; finalQuery != expected {
+		t.Errorf("expected %q, got %q", expected, finalQuery)
+	}
+}
@@ -26,14 +26,7 @@ type PersonDao struct {
 	GetByAge func(q Querier, id int, ages []int, name string) ([]Person, error) `proq:"SELECT * from PERSON WHERE name=:name: and age in (:ages:) and id = :id:" prop:"id,ages,name"`
 }
 
-var personDao PersonDao
-
-func init() {
-	err := Build(&personDao, Postgres)
-	if err != nil {
-		panic(err)
-	}
-}
+var personDao = MustBuild[PersonDao](Postgres, WithMaxParams(PostgresMaxParams))
 
 func DoPersonStuff(wrapper Wrapper) {
 	count, err := personDao.Create(wrapper, "Fred", 20)
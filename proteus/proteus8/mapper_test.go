@@ -0,0 +1,103 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+type audit struct {
+	CreatedAt string `prof:"created_at"`
+	UpdatedAt string `prof:"updated_at"`
+}
+
+type Address struct {
+	City string `prof:"city"`
+	Zip  string `prof:"zip"`
+}
+
+type Geo struct {
+	Lat float64 `prof:"lat"`
+}
+
+type Location struct {
+	Address
+	*Geo
+}
+
+type nestedPerson struct {
+	Updated string `prof:"updated_at"`
+	audit
+	Id       int      `prof:"id"`
+	Name     string   `prof:"name"`
+	Home     Address  `prof:"home_"`
+	Work     *Address `prof:"work_"`
+	Where    Location `prof:"loc_"`
+	Password string   `prof:"-"`
+}
+
+func TestNestedMapping(t *testing.T) {
+	var dao struct {
+		Get func(q Querier, id int) (*nestedPerson, error) `proq:"SELECT * FROM PERSON WHERE id = :id:" prop:"id"`
+	}
+	if err := Build(&dao, Postgres); err != nil {
+		t.Fatal(err)
+	}
+	fw := &fakeWrapper{
+		cols: []string{"id", "name", "created_at", "updated_at", "home_city", "home_zip", "work_city", "work_zip", "loc_city", "loc_lat", "-"},
+		rows: [][]interface{}{{int64(1), "Fred", "monday", "tuesday", "Springfield", "12345", nil, nil, "Shelbyville", 1.5, "secret"}},
+	}
+	person, err := dao.Get(fw, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := nestedPerson{
+		audit:   audit{CreatedAt: "monday"},
+		Id:      1,
+		Name:    "Fred",
+		Home:    Address{City: "Springfield", Zip: "12345"},
+		Where:   Location{Address: Address{City: "Shelbyville"}, Geo: &Geo{Lat: 1.5}},
+		Updated: "tuesday",
+	}
+	if !reflect.DeepEqual(*person, expected) {
+		t.Errorf("expected %+v, got %+v", expected, *person)
+	}
+
+	fw.rows[0][6] = "Capital City"
+	person, err = dao.Get(fw, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if person.Work == nil || person.Work.City != "Capital City" {
+		t.Errorf("expected the nested pointer to be allocated, got %+v", person.Work)
+	}
+}
+
+type lowerNested struct {
+	Nickname string `prof:"nickname"`
+}
+
+type lowerPerson struct {
+	Id int `prof:"id"`
+	*lowerNested
+	audit
+}
+
+func TestEmbeddedUnexportedPointer(t *testing.T) {
+	var dao struct {
+		Get    func(q Querier) (*lowerPerson, error)  `proq:"SELECT * FROM PERSON"`
+		GetAll func(q Querier) ([]lowerPerson, error) `proq:"SELECT * FROM PERSON"`
+	}
+	if err := Build(&dao, Postgres); err != nil {
+		t.Fatal(err)
+	}
+	fw := &fakeWrapper{cols: []string{"id", "nickname", "created_at"}, rows: [][]interface{}{{int64(1), "freddy", "monday"}}}
+	expected := lowerPerson{Id: 1, audit: audit{CreatedAt: "monday"}}
+	person, err := dao.Get(fw)
+	if err != nil || !reflect.DeepEqual(*person, expected) {
+		t.Errorf("expected %+v, got %+v, %v", expected, person, err)
+	}
+	people, err := dao.GetAll(fw)
+	if err != nil || !reflect.DeepEqual(people, []lowerPerson{expected}) {
+		t.Errorf("expected %+v, got %+v, %v", expected, people, err)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+)
+
+// NamingStrategy derives the column name for a struct field that has no prof tag.
+// A field with a prof tag always uses the tag.
+type NamingStrategy func(fieldName string) string
+
+// SnakeCase maps UserID to user_id and CreatedAt to created_at.
+func SnakeCase(fieldName string) string {
+	runes := []rune(fieldName)
+	var b bytes.Buffer
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteRune('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// LowerCase maps UserID to userid.
+func LowerCase(fieldName string) string {
+	return strings.ToLower(fieldName)
+}
+
+// WithNamingStrategy maps struct fields without a prof tag to the column named by ns.
+// Without a NamingStrategy (or WithCaseInsensitiveColumns), fields without a prof tag are skipped.
+func WithNamingStrategy(ns NamingStrategy) Option {
+	return func(o *buildOptions) {
+		o.naming = ns
+	}
+}
+
+// WithCaseInsensitiveColumns matches columns to fields regardless of case. Fields without a prof tag
+// use the field name, unless there is also a NamingStrategy.
+func WithCaseInsensitiveColumns() Option {
+	return func(o *buildOptions) {
+		o.caseInsensitive = true
+	}
+}
+
+// fieldColumn is the column for a field without a prof tag, or "" if it isn't mapped.
+func (o buildOptions) fieldColumn(fieldName string) string {
+	switch {
+	case o.naming != nil:
+		return o.naming(fieldName)
+	case o.caseInsensitive:
+		return fieldName
+	}
+	return ""
+}
+
+// columnKey is how a column name is looked up when mapping.
+func (o buildOptions) columnKey(col string) string {
+	if o.caseInsensitive {
+		return strings.ToLower(col)
+	}
+	return col
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSnakeCase(t *testing.T) {
+	data := map[string]string{
+		"Id":         "id",
+		"UserID":     "user_id",
+		"CreatedAt":  "created_at",
+		"HTTPServer": "http_server",
+		"Address2":   "address2",
+		"V2Name":     "v2_name",
+	}
+	for in, expected := range data {
+		if out := SnakeCase(in); out != expected {
+			t.Errorf("%s: expected %s, got %s", in, expected, out)
+		}
+	}
+}
+
+type untaggedPerson struct {
+	ID        int
+	FullName  string `prof:"name"`
+	CreatedAt string
+	Home      Address
+	Ignored   string `prof:"-"`
+}
+
+func TestNamingStrategies(t *testing.T) {
+	data := []struct {
+		name     string
+		options  []Option
+		cols     []string
+		expected untaggedPerson
+	}{
+		{
+			"snake case",
+			[]Option{WithNamingStrategy(SnakeCase)},
+			[]string{"id", "name", "created_at", "home_city", "ignored"},
+			untaggedPerson{ID: 1, FullName: "Fred", CreatedAt: "monday", Home: Address{City: "Springfield"}},
+		},
+		{
+			"lower case",
+			[]Option{WithNamingStrategy(LowerCase)},
+			[]string{"id", "name", "createdat", "home_city", "ignored"},
+			untaggedPerson{ID: 1, FullName: "Fred", CreatedAt: "monday", Home: Address{City: "Springfield"}},
+		},
+		{
+			"case insensitive",
+			[]Option{WithCaseInsensitiveColumns()},
+			[]string{"id", "NAME", "CREATEDAT", "HOME_CITY", "ignored"},
+			untaggedPerson{ID: 1, FullName: "Fred", CreatedAt: "monday", Home: Address{City: "Springfield"}},
+		},
+		{
+			"case insensitive snake case",
+			[]Option{WithCaseInsensitiveColumns(), WithNamingStrategy(SnakeCase)},
+			[]string{"ID", "Name", "Created_At", "home_CITY", "ignored"},
+			untaggedPerson{ID: 1, FullName: "Fred", CreatedAt: "monday", Home: Address{City: "Springfield"}},
+		},
+		{
+			"no strategy",
+			nil,
+			[]string{"id", "name", "created_at", "home_city", ""},
+			untaggedPerson{FullName: "Fred"},
+		},
+	}
+	for _, v := range data {
+		var dao struct {
+			Get func(q Querier, id int) (*untaggedPerson, error) `proq:"SELECT * FROM PERSON WHERE id = :id:" prop:"id"`
+		}
+		if err := Build(&dao, Postgres, v.options...); err != nil {
+			t.Fatal(err)
+		}
+		fw := &fakeWrapper{
+			cols: v.cols,
+			rows: [][]interface{}{{int64(1), "Fred", "monday", "Springfield", "nope"}},
+		}
+		person, err := dao.Get(fw, 1)
+		if err != nil {
+			t.Fatalf("%s: %v", v.name, err)
+		}
+		if !reflect.DeepEqual(*person, v.expected) {
+			t.Errorf("%s: expected %+v, got %+v", v.name, v.expected, *person)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+)
+
+// OptionsProvider is implemented by DAO structs that need options of their own. When Build reaches a DAO
+// that implements it, whether it's the one passed to Build or one nested inside it, the options it returns
+// are applied on top of the ones it inherited.
+type OptionsProvider interface {
+	BuildOptions() []Option
+}
+
+var optionsProviderType = reflect.TypeOf((*OptionsProvider)(nil)).Elem()
+
+func providedOptions(daoValue reflect.Value, opts buildOptions) buildOptions {
+	var provider OptionsProvider
+	switch {
+	case daoValue.CanAddr() && daoValue.Addr().Type().Implements(optionsProviderType):
+		provider = daoValue.Addr().Interface().(OptionsProvider)
+	case daoValue.Type().Implements(optionsProviderType):
+		provider = daoValue.Interface().(OptionsProvider)
+	default:
+		return opts
+	}
+	for _, o := range provider.BuildOptions() {
+		o(&opts)
+	}
+	return opts
+}
+
+// buildNested builds a field that holds a DAO, so that a struct can be made up of other DAOs:
+//
+//	type Repository struct {
+//		PersonDao
+//		Orders *OrderDao `proempty:"skip"`
+//	}
+//
+// Embedded and named fields are built if they are structs, or pointers to structs, with funcs to implement
+// somewhere inside them; a nil pointer is set to a new struct. The options passed to Build are inherited,
+// and can be overridden by the proempty and prosoftdelete tags on the field, and by an OptionsProvider.
+// Other fields are left alone.
+func buildNested(fieldValue reflect.Value, field reflect.StructField, paramAdapter ParamAdapter, opts buildOptions, inProgress map[reflect.Type]bool) error {
+	structType := field.Type
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct || !hasQueries(structType, inProgress) {
+		return nil
+	}
+	if field.PkgPath != "" && !field.Anonymous {
+		//unexported
+		return nil
+	}
+	if field.Type.Kind() == reflect.Ptr {
+		if fieldValue.IsNil() {
+			if !fieldValue.CanSet() {
+				return errors.New("can't allocate a nil pointer to an unexported embedded DAO")
+			}
+			fieldValue.Set(reflect.New(structType))
+		}
+		fieldValue = fieldValue.Elem()
+	}
+	fieldOpts, err := fieldOptions(opts, field)
+	if err != nil {
+		return err
+	}
+	return buildStruct(fieldValue, paramAdapter, fieldOpts, inProgress)
+}
+
+// hasQueries reports if structType, or a struct inside it, has a func field with a proq tag.
+// Types that are already being built are skipped, so a struct that points to itself doesn't recurse forever.
+func hasQueries(structType reflect.Type, inProgress map[reflect.Type]bool) bool {
+	if inProgress[structType] {
+		return false
+	}
+	inProgress[structType] = true
+	defer delete(inProgress, structType)
+	for i := 0; i < structType.NumField(); i++ {
+		sf := structType.Field(i)
+		if _, ok := sf.Tag.Lookup("proq"); ok && sf.Type.Kind() == reflect.Func {
+			return true
+		}
+		t := sf.Type
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() == reflect.Struct && hasQueries(t, inProgress) {
+			return true
+		}
+	}
+	return false
+}
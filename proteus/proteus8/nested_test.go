@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+type nestedOrderDao struct {
+	ByIds func(q Querier, ids []int) ([]Person, error) `proq:"SELECT * FROM ORDERS WHERE id IN (:ids:)" prop:"ids"`
+}
+
+type skipOrderDao struct {
+	ByIds func(q Querier, ids []int) ([]Person, error) `proq:"SELECT * FROM ORDERS WHERE id IN (:ids:)" prop:"ids"`
+}
+
+func (skipOrderDao) BuildOptions() []Option {
+	return []Option{WithEmptySlice(EmptySliceSkip)}
+}
+
+type repository struct {
+	PersonDao
+	Orders     nestedOrderDao
+	Skip       *skipOrderDao
+	FalseDao   *nestedOrderDao `proempty:"false"`
+	Next       *repository
+	Config     struct{ Name string }
+	unexported nestedOrderDao
+}
+
+func TestNestedBuild(t *testing.T) {
+	var repo repository
+	if err := Build(&repo, Postgres); err != nil {
+		t.Fatal(err)
+	}
+	if repo.Get == nil || repo.Orders.ByIds == nil || repo.Skip == nil || repo.Skip.ByIds == nil || repo.FalseDao.ByIds == nil {
+		t.Fatal("expected the embedded and nested DAOs to be built")
+	}
+	if repo.Next != nil || repo.unexported.ByIds != nil {
+		t.Error("expected a recursive pointer and an unexported field to be left alone")
+	}
+
+	fw := &fakeWrapper{cols: []string{"id", "name", "age"}}
+	if _, err := repo.Orders.ByIds(fw, nil); !errors.Is(err, ErrEmptySlice) {
+		t.Errorf("expected the inherited EmptySliceError, got %v", err)
+	}
+	if _, err := repo.Skip.ByIds(fw, nil); err != nil {
+		t.Errorf("expected the OptionsProvider to skip the query, got %v", err)
+	}
+	if _, err := repo.FalseDao.ByIds(fw, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(fw.queries) != 1 || fw.queries[0] != "SELECT * FROM ORDERS WHERE 1=0" {
+		t.Errorf("expected the proempty tag to override, got %q", fw.queries)
+	}
+
+	type badNested struct {
+		Orders struct {
+			Get func(id int) error `proq:"SELECT 1"`
+		}
+	}
+	var bad badNested
+	if err := Build(&bad, Postgres); err == nil {
+		t.Error("expected an error from a nested DAO")
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Option changes how Build implements the functions in a DAO.
+type Option func(*buildOptions)
+
+type buildOptions struct {
+	emptySlice  EmptySliceMode
+	maxParams   int
+	sliceArrays bool
+	returning   bool
+	returnCol   string
+	keyCol      string
+	strict      bool
+	paramTags   map[string]profTag
+	keys        KeyProvider
+	version     *versionLock
+	softDelete  string
+	clock       func() time.Time
+	audit       func([]reflect.Value) error
+	//derived holds the values of the params that aren't func params, like those added by rewriting a query
+	derived map[string]derivedValue
+
+	naming          NamingStrategy
+	caseInsensitive bool
+}
+
+func makeBuildOptions(options []Option) buildOptions {
+	var opts buildOptions
+	for _, o := range options {
+		o(&opts)
+	}
+	return opts
+}
+
+// EmptySliceMode controls what happens when a slice parameter has no elements.
+type EmptySliceMode int
+
+const (
+	// EmptySliceError returns an error wrapping ErrEmptySlice without running the query.
+	EmptySliceError EmptySliceMode = iota
+	// EmptySliceFalse replaces an IN predicate with 1=0, so that x IN (:ids:) matches no rows, and a NOT IN
+	// predicate with 1=1, so that x NOT IN (:ids:) matches every row. Elsewhere, the placeholder list is
+	// replaced with NULL. Build returns an error for a NOT IN whose left side can't be found.
+	EmptySliceFalse
+	// EmptySliceSkip doesn't run the query and returns the zero value for the result with no error.
+	EmptySliceSkip
+)
+
+// ErrEmptySlice is returned when a slice parameter is empty and the EmptySliceMode is EmptySliceError.
+var ErrEmptySlice = errors.New("empty slice parameter")
+
+// WithEmptySlice sets the EmptySliceMode for every function in the DAO.
+// A function can override it with a proempty tag whose value is error, false, or skip.
+func WithEmptySlice(mode EmptySliceMode) Option {
+	return func(o *buildOptions) {
+		o.emptySlice = mode
+	}
+}
+
+// WithMaxParams sets the most bind parameters a single query can use. When slice parameters
+// expand past the limit, the call is split into several queries: slice results are concatenated,
+// a single result comes from the first query that finds a row, and RowsAffected is summed.
+// Only a slice used once, as the list of an IN predicate ANDed into the WHERE clause, is split;
+// a call that needs any other slice split returns an error. Funcs with a proreturn tag are never split.
+// The default of 0 never splits a call. The *MaxParams constants hold the limits for each database.
+func WithMaxParams(maxParams int) Option {
+	return func(o *buildOptions) {
+		o.maxParams = maxParams
+	}
+}
+
+// WithSliceArrays binds each slice parameter as a single Postgres array using pq.Array, instead of
+// expanding it into one placeholder per element. Write the query with = ANY(:ids:) rather than IN (:ids:).
+// The query text is the same for every slice length, so the database can reuse its plan.
+// A []byte parameter is still bound as bytes, and a slice of structs is still expanded into tuples.
+// Enum values are checked and encrypted elements are encrypted one by one, as they are for an expanded slice.
+// A function can opt in or out with a proarray tag whose value is true or false.
+func WithSliceArrays() Option {
+	return func(o *buildOptions) {
+		o.sliceArrays = true
+	}
+}
+
+// WithReturning is for databases that support INSERT ... RETURNING, like Postgres and SQLite 3.35 or later.
+// Executor funcs with a proreturn tag naming the generated column run through Query with a RETURNING clause,
+// so their first param must be a Wrapper, or another type that is both an Executor and a Querier.
+// A func returning an integer gets that column, and a func returning a pointer to struct gets the whole row.
+// Without this option those funcs run through Exec and use LastInsertId instead; a struct is returned
+// with only the field whose prof tag matches the proreturn column set.
+func WithReturning() Option {
+	return func(o *buildOptions) {
+		o.returning = true
+	}
+}
+
+// fieldOptions applies the tags on a DAO field that override the DAO-wide options.
+func fieldOptions(opts buildOptions, field reflect.StructField) (buildOptions, error) {
+	if mode, ok := field.Tag.Lookup("proempty"); ok {
+		switch mode {
+		case "error":
+			opts.emptySlice = EmptySliceError
+		case "false":
+			opts.emptySlice = EmptySliceFalse
+		case "skip":
+			opts.emptySlice = EmptySliceSkip
+		default:
+			return opts, fmt.Errorf("invalid proempty value %q on field %s", mode, field.Name)
+		}
+	}
+	if mode, ok := field.Tag.Lookup("proarray"); ok {
+		switch mode {
+		case "true":
+			opts.sliceArrays = true
+		case "false":
+			opts.sliceArrays = false
+		default:
+			return opts, fmt.Errorf("invalid proarray value %q on field %s", mode, field.Name)
+		}
+	}
+	if col, ok := field.Tag.Lookup("proreturn"); ok {
+		opts.returnCol = col
+	}
+	if col, ok := field.Tag.Lookup("prokey"); ok {
+		opts.keyCol = col
+	}
+	if col, ok := field.Tag.Lookup("prosoftdelete"); ok {
+		if col == "-" {
+			col = ""
+		}
+		opts.softDelete = col
+	}
+	return opts, nil
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+type emptySliceDao struct {
+	Default   func(q Querier, ages []int) ([]Person, error)       `proq:"SELECT * FROM PERSON WHERE age IN (:ages:)" prop:"ages"`
+	Skip      func(q Querier, ages []int) ([]Person, error)       `proq:"SELECT * FROM PERSON WHERE age IN (:ages:)" prop:"ages" proempty:"skip"`
+	SkipExec  func(e Executor, ids []int) (int64, error)          `proq:"DELETE FROM PERSON WHERE id IN (:ids:)" prop:"ids" proempty:"skip"`
+	ErrorExec func(e Executor, ids []int) (int64, error)          `proq:"DELETE FROM PERSON WHERE id IN (:ids:)" prop:"ids"`
+	Filled    func(q Querier, ages []int) ([]Person, error)       `proq:"SELECT * FROM PERSON WHERE age IN (:ages:)" prop:"ages" proempty:"skip"`
+	FalseGet  func(q Querier, ids []int, id int) (*Person, error) `proq:"SELECT * FROM PERSON WHERE id IN (:ids:) OR id = :id:" prop:"ids,id" proempty:"false"`
+}
+
+func TestEmptySlice(t *testing.T) {
+	var dao emptySliceDao
+	if err := Build(&dao, Postgres); err != nil {
+		t.Fatal(err)
+	}
+
+	fw := &fakeWrapper{}
+	people, err := dao.Default(fw, nil)
+	if !errors.Is(err, ErrEmptySlice) || people != nil || len(fw.queries) != 0 {
+		t.Errorf("default: expected ErrEmptySlice without a query, got %v, %v, %v", people, err, fw.queries)
+	}
+
+	count, err := dao.ErrorExec(fw, []int{})
+	if !errors.Is(err, ErrEmptySlice) || count != 0 || len(fw.queries) != 0 {
+		t.Errorf("error exec: expected ErrEmptySlice without a query, got %v, %v, %v", count, err, fw.queries)
+	}
+
+	people, err = dao.Skip(fw, []int{})
+	if err != nil || people != nil || len(fw.queries) != 0 {
+		t.Errorf("skip: expected no result and no query, got %v, %v, %v", people, err, fw.queries)
+	}
+
+	count, err = dao.SkipExec(fw, []int{})
+	if err != nil || count != 0 || len(fw.queries) != 0 {
+		t.Errorf("skip exec: expected no result and no query, got %v, %v, %v", count, err, fw.queries)
+	}
+
+	_, err = dao.FalseGet(fw, []int{}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "SELECT * FROM PERSON WHERE 1=0 OR id = $1"; fw.queries[0] != expected {
+		t.Errorf("false: expected %q, got %q", expected, fw.queries[0])
+	}
+	if len(fw.args[0]) != 1 || fw.args[0][0] != 3 {
+		t.Errorf("false: unexpected args %v", fw.args[0])
+	}
+
+	_, err = dao.Filled(fw, []int{20, 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "SELECT * FROM PERSON WHERE age IN ($1, $2)"; fw.queries[1] != expected {
+		t.Errorf("filled: expected %q, got %q", expected, fw.queries[1])
+	}
+}
+
+func TestEmptySliceOption(t *testing.T) {
+	var dao struct {
+		GetByAge func(q Querier, ages []int) ([]Person, error) `proq:"SELECT * FROM PERSON WHERE age IN (:ages:)" prop:"ages"`
+		Strict   func(q Querier, ages []int) ([]Person, error) `proq:"SELECT * FROM PERSON WHERE age IN (:ages:)" prop:"ages" proempty:"error"`
+	}
+	if err := Build(&dao, Postgres, WithEmptySlice(EmptySliceSkip)); err != nil {
+		t.Fatal(err)
+	}
+	fw := &fakeWrapper{}
+	if _, err := dao.GetByAge(fw, nil); err != nil || len(fw.queries) != 0 {
+		t.Errorf("expected the query to be skipped, got %v, %v", err, fw.queries)
+	}
+	if _, err := dao.Strict(fw, nil); !errors.Is(err, ErrEmptySlice) {
+		t.Errorf("expected the field to override the option, got %v", err)
+	}
+
+	var bad struct {
+		GetByAge func(q Querier, ages []int) ([]Person, error) `proq:"SELECT * FROM PERSON WHERE age IN (:ages:)" prop:"ages" proempty:"nope"`
+	}
+	if err := Build(&bad, Postgres); err == nil {
+		t.Error("expected an invalid proempty value to fail")
+	}
+}
+
+func TestSliceArrays(t *testing.T) {
+	var dao struct {
+		GetByAge func(q Querier, ages []int) ([]Person, error)            `proq:"SELECT * FROM PERSON WHERE age = ANY(:ages:)" prop:"ages"`
+		GetByKey func(q Querier, key []byte, ids []int) ([]Person, error) `proq:"SELECT * FROM PERSON WHERE key = :key: AND id = ANY(:ids:)" prop:"key,ids"`
+	}
+	if err := Build(&dao, Postgres, WithSliceArrays()); err != nil {
+		t.Fatal(err)
+	}
+	fw := &fakeWrapper{}
+	for _, ages := range [][]int{{20}, {20, 30, 40}, {}} {
+		if _, err := dao.GetByAge(fw, ages); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, v := range fw.queries {
+		if v != "SELECT * FROM PERSON WHERE age = ANY($1)" {
+			t.Errorf("unexpected query %q", v)
+		}
+		if len(fw.args[i]) != 1 {
+			t.Fatalf("expected a single array arg, got %v", fw.args[i])
+		}
+		if _, ok := fw.args[i][0].(driver.Valuer); !ok {
+			t.Errorf("expected the slice to be bound as an array, got %T", fw.args[i][0])
+		}
+	}
+
+	fw = &fakeWrapper{}
+	if _, err := dao.GetByKey(fw, []byte("abc"), []int{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fw.args[0][0].([]byte); !ok || len(fw.args[0]) != 2 {
+		t.Errorf("expected []byte to be bound as-is, got %v", fw.args[0])
+	}
+}
+
+func TestSliceArrayOptions(t *testing.T) {
+	keys := KeyRing{Current: "k1", Keys: map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)}}
+	var dao struct {
+		ByStatus func(q Querier, statuses []status) ([]Person, error) `proq:"SELECT * FROM PERSON WHERE status = ANY(:statuses:)" prop:"statuses"`
+		BySSN    func(q Querier, ssns []string) ([]Person, error)     `proq:"SELECT * FROM PERSON WHERE ssn = ANY(:ssns:)" prop:"ssns:encrypted" proarray:"true"`
+		ByAge    func(q Querier, ages []int) ([]Person, error)        `proq:"SELECT * FROM PERSON WHERE age IN (:ages:)" prop:"ages" proarray:"false"`
+	}
+	if err := Build(&dao, Postgres, WithSliceArrays(), WithKeyProvider(keys)); err != nil {
+		t.Fatal(err)
+	}
+	fw := &fakeWrapper{}
+	if _, err := dao.ByStatus(fw, []status{active, "deleted"}); !errors.Is(err, ErrInvalidEnum) {
+		t.Errorf("expected ErrInvalidEnum for an array element, got %v", err)
+	}
+	if _, err := dao.BySSN(fw, []string{"123-45-6789"}); err != nil {
+		t.Fatal(err)
+	}
+	arr, ok := fw.args[0][0].(pq.GenericArray)
+	if !ok {
+		t.Fatalf("expected the slice to be bound as an array, got %T", fw.args[0][0])
+	}
+	if ssns, ok := arr.A.([]interface{}); !ok || len(ssns) != 1 || !strings.HasPrefix(ssns[0].(string), "k1:") {
+		t.Errorf("expected the array elements to be encrypted, got %v", arr.A)
+	}
+	if _, err := dao.ByAge(fw, []int{20, 30}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "SELECT * FROM PERSON WHERE age IN ($1, $2)"; fw.queries[1] != expected {
+		t.Errorf("expected proarray:\"false\" to expand the slice, got %q", fw.queries[1])
+	}
+
+	var optIn struct {
+		ByAge func(q Querier, ages []int) ([]Person, error) `proq:"SELECT * FROM PERSON WHERE age = ANY(:ages:)" prop:"ages" proarray:"true"`
+	}
+	if err := Build(&optIn, Postgres); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := optIn.ByAge(fw, []int{20, 30}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "SELECT * FROM PERSON WHERE age = ANY($1)"; fw.queries[2] != expected {
+		t.Errorf("expected proarray:\"true\" to bind an array, got %q", fw.queries[2])
+	}
+
+	var bad struct {
+		ByAge func(q Querier, ages []int) ([]Person, error) `proq:"SELECT * FROM PERSON WHERE age = ANY(:ages:)" prop:"ages" proarray:"yes"`
+	}
+	if err := Build(&bad, Postgres); err == nil {
+		t.Error("expected an invalid proarray value to fail")
+	}
+}
+
+func TestEmptySliceFalse(t *testing.T) {
+	var dao struct {
+		NotIn    func(q Querier, ids []int) ([]Person, error)           `proq:"SELECT * FROM PERSON WHERE id NOT IN ( :ids: ) ORDER BY id" prop:"ids"`
+		Func     func(q Querier, names []string) ([]Person, error)      `proq:"SELECT * FROM PERSON WHERE lower(p.\"name\") in (:names:)" prop:"names"`
+		Both     func(q Querier, a, b []int, age int) ([]Person, error) `proq:"SELECT * FROM PERSON WHERE (id IN (:a:) OR id NOT IN (:b:)) AND age = :age:" prop:"a,b,age"`
+		Inserted func(e Executor, ids []int) (int64, error)             `proq:"INSERT INTO T(id) SELECT id FROM S WHERE id IN (SELECT x FROM U WHERE y = ANY(ARRAY[:ids:]))" prop:"ids"`
+	}
+	if err := Build(&dao, Postgres, WithEmptySlice(EmptySliceFalse)); err != nil {
+		t.Fatal(err)
+	}
+	fw := &fakeWrapper{}
+	dao.NotIn(fw, nil)
+	dao.NotIn(fw, []int{1, 2})
+	dao.Func(fw, nil)
+	dao.Both(fw, nil, nil, 20)
+	dao.Both(fw, []int{1}, nil, 20)
+	dao.Inserted(fw, nil)
+	expected := []string{
+		"SELECT * FROM PERSON WHERE 1=1 ORDER BY id",
+		"SELECT * FROM PERSON WHERE id NOT IN ( $1, $2 ) ORDER BY id",
+		"SELECT * FROM PERSON WHERE 1=0",
+		"SELECT * FROM PERSON WHERE (1=0 OR 1=1) AND age = $1",
+		"SELECT * FROM PERSON WHERE (id IN ($1) OR 1=1) AND age = $2",
+		"INSERT INTO T(id) SELECT id FROM S WHERE id IN (SELECT x FROM U WHERE y = ANY(ARRAY[NULL]))",
+	}
+	if !reflect.DeepEqual(fw.queries, expected) {
+		t.Errorf("expected %q, got %q", expected, fw.queries)
+	}
+
+	var bad struct {
+		NotIn func(q Querier, ids []int) ([]Person, error) `proq:"SELECT * FROM PERSON WHERE age + 1 NOT IN (:ids:)" prop:"ids"`
+	}
+	if err := Build(&bad, Postgres, WithEmptySlice(EmptySliceFalse)); err == nil {
+		t.Error("expected an error for a NOT IN whose left side can't be found")
+	}
+	if err := Build(&bad, Postgres); err != nil {
+		t.Errorf("expected no error with EmptySliceError, got %v", err)
+	}
+
+	var notIn struct {
+		NotIn func(q Querier, name string, ids []int) ([]Person, error) `proq:"SELECT * FROM PERSON WHERE lower(:name:) NOT IN (:ids:)" prop:"name,ids"`
+	}
+	if err := Build(&notIn, Postgres, WithEmptySlice(EmptySliceFalse)); err == nil {
+		t.Error("expected an error for a NOT IN whose left side has a param")
+	}
+	//the IN predicate stays, with NULL in the list, so name is still bound
+	var in struct {
+		In func(q Querier, name string, ids []int, age int) ([]Person, error) `proq:"SELECT * FROM PERSON WHERE lower(:name:) IN (:ids:) AND age = :age:" prop:"name,ids,age"`
+	}
+	if err := Build(&in, Postgres, WithEmptySlice(EmptySliceFalse)); err != nil {
+		t.Fatal(err)
+	}
+	fw = &fakeWrapper{}
+	if _, err := in.In(fw, "x", nil, 3); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "SELECT * FROM PERSON WHERE lower($1) IN (NULL) AND age = $2"; fw.queries[0] != expected {
+		t.Errorf("expected %q, got %q", expected, fw.queries[0])
+	}
+	if expectedArgs := []interface{}{"x", 3}; !reflect.DeepEqual(fw.args[0], expectedArgs) {
+		t.Errorf("expected %v, got %v", expectedArgs, fw.args[0])
+	}
+}
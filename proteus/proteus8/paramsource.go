@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// paramSource returns the func that finds the value of a query param in the single map or params struct
+// passed to a func, or nil if the func doesn't take one. A func takes one when its only param after the
+// Executor or Querier is a map with string keys, or a struct or pointer to struct that isn't a single value
+// like a time.Time:
+//
+//	Find func(q Querier, filter map[string]interface{}) ([]Person, error) `proq:"SELECT * FROM PERSON WHERE name = :name:"`
+//
+// A param that isn't in the prop tag is the value of the map key, or the field with that prof tag or name.
+// Map keys are checked when the func is called, and struct fields when it is built. The values are bound
+// as they are; a slice isn't expanded.
+func paramSource(funcType reflect.Type, opts buildOptions) func(name string) (derivedValue, error) {
+	if funcType.NumIn() != 2 {
+		return nil
+	}
+	sourceType := funcType.In(1)
+	if sourceType.Kind() == reflect.Map && sourceType.Key().Kind() == reflect.String {
+		return func(name string) (derivedValue, error) {
+			return mapValue(sourceType, name, opts.keys), nil
+		}
+	}
+	structType := indirectType(sourceType)
+	if !isCompositeStruct(structType) {
+		return nil
+	}
+	fields := buildColFieldMap(structType, "", opts)
+	return func(name string) (derivedValue, error) {
+		info, ok := fields[opts.columnKey(name)]
+		if !ok {
+			sf, found := structType.FieldByName(name)
+			if !found || sf.PkgPath != "" {
+				return nil, fmt.Errorf("%v has no field for param %s", structType, name)
+			}
+			info = fieldInfo{name: sf.Name, fieldType: sf.Type, index: sf.Index, tag: parseProfTag(sf)}
+		}
+		return entityField(1, structColumn{name: name, info: info}, opts.keys), nil
+	}
+}
+
+// mapValue returns the value of the name key in the map passed as the first param after the Executor or Querier.
+func mapValue(mapType reflect.Type, name string, keys KeyProvider) derivedValue {
+	key := reflect.ValueOf(name).Convert(mapType.Key())
+	tag := profTag{name: name}
+	return func(args []reflect.Value) (interface{}, error) {
+		val := args[1].MapIndex(key)
+		if !val.IsValid() {
+			return nil, fmt.Errorf("no value for param %s", name)
+		}
+		if val.Kind() == reflect.Interface {
+			if val = val.Elem(); !val.IsValid() {
+				return nil, nil
+			}
+		}
+		return encodeValue(val, tag, keys)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+type personFilter struct {
+	Name   string `prof:"name"`
+	MinAge int
+	Secret string `prof:"secret,encrypted"`
+}
+
+func TestMapParams(t *testing.T) {
+	var dao struct {
+		Find   func(q Querier, filter map[string]interface{}) ([]Person, error) `proq:"SELECT * FROM PERSON WHERE name = :name: AND age > :age:"`
+		Update func(e Executor, values map[string]int) (int64, error)           `proq:"UPDATE PERSON SET age = :age: WHERE id = :id:"`
+	}
+	if err := Build(&dao, Postgres); err != nil {
+		t.Fatal(err)
+	}
+	fw := &fakeWrapper{cols: []string{"id", "name", "age"}, rows: [][]interface{}{{int64(1), "fred", int64(20)}}}
+	people, err := dao.Find(fw, map[string]interface{}{"name": "fred", "age": 10, "extra": true})
+	if err != nil || len(people) != 1 {
+		t.Errorf("unexpected people %+v, %v", people, err)
+	}
+	if _, err := dao.Find(fw, map[string]interface{}{"name": nil, "age": 10}); err != nil {
+		t.Error(err)
+	}
+	if _, err := dao.Update(fw, map[string]int{"age": 30, "id": 1}); err != nil {
+		t.Error(err)
+	}
+	if _, err := dao.Find(fw, map[string]interface{}{"name": "fred"}); err == nil {
+		t.Error("expected an error for a missing key")
+	}
+	if _, err := dao.Update(fw, nil); err == nil {
+		t.Error("expected an error for a nil map")
+	}
+	expectedQueries := []string{
+		"SELECT * FROM PERSON WHERE name = $1 AND age > $2",
+		"SELECT * FROM PERSON WHERE name = $1 AND age > $2",
+		"UPDATE PERSON SET age = $1 WHERE id = $2",
+	}
+	expectedArgs := [][]interface{}{{"fred", 10}, {nil, 10}, {30, 1}}
+	if !reflect.DeepEqual(fw.queries, expectedQueries) {
+		t.Errorf("expected %q, got %q", expectedQueries, fw.queries)
+	}
+	if !reflect.DeepEqual(fw.args, expectedArgs) {
+		t.Errorf("expected %#v, got %#v", expectedArgs, fw.args)
+	}
+}
+
+func TestStructParams(t *testing.T) {
+	var dao struct {
+		Find    func(q Querier, filter personFilter) ([]Person, error) `proq:"SELECT * FROM PERSON WHERE name = :name: AND age > :MinAge:"`
+		FindPtr func(q Querier, filter *personFilter) (*Person, error) `proq:"SELECT * FROM PERSON WHERE name = :name:"`
+		Secret  func(e Executor, filter personFilter) (int64, error)   `proq:"UPDATE PERSON SET ssn = :secret: WHERE name = :name:"`
+		Named   func(q Querier, filter personFilter) ([]Person, error) `proq:"SELECT * FROM PERSON WHERE name = :filter:" prop:"filter:json"`
+	}
+	keys := KeyRing{Current: "k1", Keys: map[string][]byte{"k1": make([]byte, 32)}}
+	if err := Build(&dao, Postgres, WithKeyProvider(keys)); err != nil {
+		t.Fatal(err)
+	}
+	fw := &fakeWrapper{cols: []string{"id", "name", "age"}, rows: [][]interface{}{{int64(1), "fred", int64(20)}}}
+	if _, err := dao.Find(fw, personFilter{Name: "fred", MinAge: 10}); err != nil {
+		t.Error(err)
+	}
+	if _, err := dao.FindPtr(fw, nil); err != nil {
+		t.Error(err)
+	}
+	if _, err := dao.Secret(fw, personFilter{Name: "fred", Secret: "123"}); err != nil {
+		t.Error(err)
+	}
+	if _, err := dao.Named(fw, personFilter{Name: "fred"}); err != nil {
+		t.Error(err)
+	}
+	expectedArgs := [][]interface{}{{"fred", 10}, {nil}}
+	if !reflect.DeepEqual(fw.args[:2], expectedArgs) {
+		t.Errorf("expected %#v, got %#v", expectedArgs, fw.args[:2])
+	}
+	if secret, err := decrypt(keys, []byte(fw.args[2][0].(string))); err != nil || string(secret) != "123" {
+		t.Errorf("expected the encrypted secret, got %q, %v", secret, err)
+	}
+	if fw.args[3][0] != `{"Name":"fred","MinAge":0,"Secret":""}` {
+		t.Errorf("expected the whole struct as json, got %v", fw.args[3][0])
+	}
+
+	var bad struct {
+		Find func(q Querier, filter personFilter) ([]Person, error) `proq:"SELECT * FROM PERSON WHERE age = :age:"`
+	}
+	if err := Build(&bad, Postgres); err == nil {
+		t.Error("expected an error for a param that isn't a field")
+	}
+}
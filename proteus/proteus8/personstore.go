@@ -0,0 +1,24 @@
+package main
+
+import "context"
+
+//go:generate go run ./cmd/proteusgen -type PersonStore -output personstore_proteus.go
+
+// PersonStore is the DAO for people, written as an interface so that it can be mocked.
+// proteusgen implements it from the queries in the proteus:query directives.
+type PersonStore interface {
+	//proteus:query INSERT INTO PERSON(name, age) VALUES(:name:, :age:)
+	Create(ctx context.Context, e Executor, name string, age int) (int64, error)
+
+	// Get returns nil if there's no person with the id.
+	//proteus:query SELECT * FROM PERSON WHERE id = :id:
+	Get(ctx context.Context, q Querier, id int) (*Person, error)
+
+	//proteus:query SELECT * from PERSON
+	//proteus:query WHERE name=:name: and age in (:ages:)
+	GetByAge(ctx context.Context, q Querier, name string, ages []int) ([]Person, error)
+
+	//proteus:query DELETE FROM PERSON WHERE id = :personID:
+	//proteus:params personID
+	Delete(ctx context.Context, e Executor, id int) error
+}
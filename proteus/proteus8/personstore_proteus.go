@@ -0,0 +1,70 @@
+// Code generated by proteusgen. DO NOT EDIT.
+
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// personStore implements PersonStore with funcs built by BuildFunc.
+type personStore struct {
+	createFunc   func(e Executor, name string, age int) (int64, error)
+	getFunc      func(q Querier, id int) (*Person, error)
+	getByAgeFunc func(q Querier, name string, ages []int) ([]Person, error)
+	deleteFunc   func(e Executor, id int) error
+}
+
+// NewPersonStore returns a PersonStore that runs the queries from its proteus:query directives.
+func NewPersonStore(paramAdapter ParamAdapter, options ...Option) (PersonStore, error) {
+	var out personStore
+	var err error
+	out.createFunc, err = BuildFunc[func(e Executor, name string, age int) (int64, error)]("INSERT INTO PERSON(name, age) VALUES(:name:, :age:)", "name,age", paramAdapter, options...)
+	if err != nil {
+		return nil, fmt.Errorf("PersonStore.Create: %w", err)
+	}
+	out.getFunc, err = BuildFunc[func(q Querier, id int) (*Person, error)]("SELECT * FROM PERSON WHERE id = :id:", "id", paramAdapter, options...)
+	if err != nil {
+		return nil, fmt.Errorf("PersonStore.Get: %w", err)
+	}
+	out.getByAgeFunc, err = BuildFunc[func(q Querier, name string, ages []int) ([]Person, error)]("SELECT * from PERSON WHERE name=:name: and age in (:ages:)", "name,ages", paramAdapter, options...)
+	if err != nil {
+		return nil, fmt.Errorf("PersonStore.GetByAge: %w", err)
+	}
+	out.deleteFunc, err = BuildFunc[func(e Executor, id int) error]("DELETE FROM PERSON WHERE id = :personID:", "personID", paramAdapter, options...)
+	if err != nil {
+		return nil, fmt.Errorf("PersonStore.Delete: %w", err)
+	}
+	return &out, nil
+}
+
+func (s *personStore) Create(ctx context.Context, e Executor, name string, age int) (int64, error) {
+	if ctx.Err() != nil {
+		var r0 int64
+		return r0, ctx.Err()
+	}
+	return s.createFunc(e, name, age)
+}
+
+func (s *personStore) Get(ctx context.Context, q Querier, id int) (*Person, error) {
+	if ctx.Err() != nil {
+		var r0 *Person
+		return r0, ctx.Err()
+	}
+	return s.getFunc(q, id)
+}
+
+func (s *personStore) GetByAge(ctx context.Context, q Querier, name string, ages []int) ([]Person, error) {
+	if ctx.Err() != nil {
+		var r0 []Person
+		return r0, ctx.Err()
+	}
+	return s.getByAgeFunc(q, name, ages)
+}
+
+func (s *personStore) Delete(ctx context.Context, e Executor, id int) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return s.deleteFunc(e, id)
+}
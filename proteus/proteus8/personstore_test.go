@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestPersonStore(t *testing.T) {
+	store, err := NewPersonStore(Postgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw := &fakeWrapper{cols: []string{"id", "name", "age"}, rows: [][]interface{}{{int64(1), "fred", int64(20)}}}
+	ctx := context.Background()
+	person, err := store.Get(ctx, fw, 1)
+	if err != nil || !reflect.DeepEqual(*person, Person{Id: 1, Name: "fred", Age: 20}) {
+		t.Errorf("unexpected person %+v, %v", person, err)
+	}
+	if _, err := store.GetByAge(ctx, fw, "fred", []int{20, 30}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(ctx, fw, 1); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"SELECT * FROM PERSON WHERE id = $1",
+		"SELECT * from PERSON WHERE name=$1 and age in ($2, $3)",
+		"DELETE FROM PERSON WHERE id = $1",
+	}
+	if !reflect.DeepEqual(fw.queries, expected) {
+		t.Errorf("expected %q, got %q", expected, fw.queries)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := store.Create(canceled, fw, "bob", 30); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if len(fw.queries) != 3 {
+		t.Error("expected no query to run after the context was canceled")
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+)
+
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// profTag is a parsed prof struct tag: the column name, followed by comma-separated options.
+//
+// The options are:
+//
+//	key        the column identifies the row, for grouping joined rows and as the primary key for NewCrud
+//	generated  the key column is generated by the database, so NewCrud leaves it out of inserts
+//	many       the field is a slice of structs filled from joined rows; the name is the prefix of their columns
+//	json       the column holds JSON that is unmarshaled into the field, and the field is marshaled when bound
+//	encrypted  the column is decrypted with the KeyProvider, and the field is encrypted when bound
+//	version    the column holds a version number that update funcs check and increment
+//	created    the column holds the time the row was inserted, set by insert funcs
+//	updated    the column holds the time the row was last changed, set by insert and update funcs
+//
+// A name of - skips the field.
+type profTag struct {
+	name    string
+	options []string
+}
+
+func parseProfTag(sf reflect.StructField) profTag {
+	parts := strings.Split(sf.Tag.Get("prof"), ",")
+	return profTag{name: parts[0], options: parts[1:]}
+}
+
+func (pt profTag) has(option string) bool {
+	for _, v := range pt.options {
+		if v == option {
+			return true
+		}
+	}
+	return false
+}
+
+// isCompositeStruct reports if t is a struct whose fields map to columns, rather than a struct
+// that is a single value to the driver, like time.Time or sql.NullString.
+func isCompositeStruct(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != timeType &&
+		!t.Implements(valuerType) && !reflect.PtrTo(t).Implements(valuerType) &&
+		!reflect.PtrTo(t).Implements(scannerType)
+}
@@ -2,14 +2,16 @@ package main
 
 import (
 	"bytes"
+	"database/sql"
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 	"text/template"
 )
 
-func Build(dao interface{}, paramAdapter ParamAdapter) error {
+func Build(dao interface{}, paramAdapter ParamAdapter, options ...Option) error {
 	daoPointerType := reflect.TypeOf(dao)
 	//must be a pointer to struct
 	if daoPointerType.Kind() != reflect.Ptr {
@@ -20,12 +22,24 @@ func Build(dao interface{}, paramAdapter ParamAdapter) error {
 	if daoType.Kind() != reflect.Struct {
 		return errors.New("Not a pointer to struct")
 	}
-	daoPointerValue := reflect.ValueOf(dao)
-	daoValue := reflect.Indirect(daoPointerValue)
+	daoValue := reflect.ValueOf(dao).Elem()
+	return buildStruct(daoValue, paramAdapter, makeBuildOptions(options), map[reflect.Type]bool{})
+}
+
+// buildStruct implements the funcs in daoValue, and in the DAO structs it holds. See Build.
+func buildStruct(daoValue reflect.Value, paramAdapter ParamAdapter, opts buildOptions, inProgress map[reflect.Type]bool) error {
+	daoType := daoValue.Type()
+	inProgress[daoType] = true
+	defer delete(inProgress, daoType)
+
+	opts = providedOptions(daoValue, opts)
 	for i := 0; i < daoType.NumField(); i++ {
 		curField := daoType.Field(i)
 		query, ok := curField.Tag.Lookup("proq")
 		if curField.Type.Kind() != reflect.Func || !ok {
+			if err := buildNested(daoValue.Field(i), curField, paramAdapter, opts, inProgress); err != nil {
+				return fmt.Errorf("%s: %w", curField.Name, err)
+			}
 			continue
 		}
 		funcType := curField.Type
@@ -33,7 +47,13 @@ func Build(dao interface{}, paramAdapter ParamAdapter) error {
 		paramOrder := curField.Tag.Get("prop")
 		nameOrderMap := buildNameOrderMap(paramOrder)
 
-		implementation, err := makeImplementation(funcType, query, paramAdapter, nameOrderMap)
+		fieldOpts, err := fieldOptions(opts, curField)
+		if err != nil {
+			return err
+		}
+		fieldOpts.paramTags = buildParamTags(paramOrder)
+
+		implementation, err := makeImplementation(funcType, query, paramAdapter, nameOrderMap, fieldOpts)
 		if err != nil {
 			return err
 		}
@@ -48,82 +68,211 @@ func buildNameOrderMap(paramOrder string) map[string]int {
 	out := map[string]int{}
 	params := strings.Split(paramOrder, ",")
 	for k, v := range params {
-		out[v] = k + 1
+		out[parseParamTag(v).name] = k + 1
+	}
+	return out
+}
+
+// buildParamTags returns the options for each name in a prop tag. A name is followed by its options,
+// separated by colons, as in prop:"id,settings:json".
+func buildParamTags(paramOrder string) map[string]profTag {
+	out := map[string]profTag{}
+	for _, v := range strings.Split(paramOrder, ",") {
+		tag := parseParamTag(v)
+		out[tag.name] = tag
 	}
 	return out
 }
 
+func parseParamTag(param string) profTag {
+	parts := strings.Split(param, ":")
+	return profTag{name: parts[0], options: parts[1:]}
+}
+
 var exType = reflect.TypeOf((*Executor)(nil)).Elem()
 var qType = reflect.TypeOf((*Querier)(nil)).Elem()
 
-func makeImplementation(funcType reflect.Type, query string, paramAdapter ParamAdapter, nameOrderMap map[string]int) (func([]reflect.Value) []reflect.Value, error) {
+func makeImplementation(funcType reflect.Type, query string, paramAdapter ParamAdapter, nameOrderMap map[string]int, opts buildOptions) (func([]reflect.Value) []reflect.Value, error) {
 	if funcType.NumIn() == 0 {
 		return nil, errors.New("need to supply an Executor or Querier parameter")
 	}
 	switch fType := funcType.In(0); {
 	case fType.Implements(exType):
-		fixedQuery, paramOrder, err := buildFixedQueryAndParamOrder(query, nameOrderMap, funcType, paramAdapter)
+		opts.derived = copyDerived(opts.derived)
+		lock, err := findVersionLock(funcType, query, opts)
 		if err != nil {
 			return nil, err
 		}
-		return makeExecutorImplementation(funcType, fixedQuery, paramOrder)
+		if lock != nil {
+			if query, err = lock.rewrite(query); err != nil {
+				return nil, err
+			}
+			opts.version = lock
+			opts.derived[oldVersionParam] = lock.oldVersion
+		}
+		if query, err = softDeleteQuery(query, opts); err != nil {
+			return nil, err
+		}
+		opts.derived[nowParam] = opts.now
+		if opts.audit, err = buildAuditHooks(funcType, query, opts); err != nil {
+			return nil, err
+		}
+		if opts.returnCol != "" && opts.returning && funcType.NumOut() > 0 {
+			if query, err = returningQuery(query, funcType, opts.returnCol); err != nil {
+				return nil, err
+			}
+		}
+		fixedQuery, paramOrder, err := buildFixedQueryAndParamOrder(query, nameOrderMap, funcType, paramAdapter, opts)
+		if err != nil {
+			return nil, err
+		}
+		if opts.returnCol != "" {
+			return makeReturningImplementation(funcType, fixedQuery, paramOrder, opts)
+		}
+		return makeExecutorImplementation(funcType, fixedQuery, paramOrder, opts)
 	case fType.Implements(qType):
-		fixedQuery, paramOrder, err := buildFixedQueryAndParamOrder(query, nameOrderMap, funcType, paramAdapter)
+		query, err := softSelectQuery(query, opts)
+		if err != nil {
+			return nil, err
+		}
+		fixedQuery, paramOrder, err := buildFixedQueryAndParamOrder(query, nameOrderMap, funcType, paramAdapter, opts)
 		if err != nil {
 			return nil, err
 		}
-		return makeQuerierImplementation(funcType, fixedQuery, paramOrder)
+		return makeQuerierImplementation(funcType, fixedQuery, paramOrder, opts)
 	default:
 		return nil, errors.New("first parameter must be of type Executor or Querier")
 	}
 }
 
+// derivedValue returns the value bound for a param that is worked out from the func's params.
+type derivedValue func(args []reflect.Value) (interface{}, error)
+
+// copyDerived copies derived, so that params can be added for one func without changing the others.
+func copyDerived(derived map[string]derivedValue) map[string]derivedValue {
+	out := make(map[string]derivedValue, len(derived))
+	for k, v := range derived {
+		out[k] = v
+	}
+	return out
+}
+
 type paramInfo struct {
 	name        string
 	posInParams int
 	isSlice     bool
+	//splittable is set for a slice that chunkArgs can split across queries
+	splittable  bool
+	asArray     bool
+	tupleFields []structColumn
+	//tag holds the options from the prop tag, and tupleTags the prof tags of the tuple fields
+	tag       profTag
+	tupleTags []profTag
+	//value is set when the value isn't a param, but is worked out from the params, like the time of the call
+	value derivedValue
 }
 
-func buildFixedQueryAndParamOrder(query string, nameOrderMap map[string]int, funcType reflect.Type, pa ParamAdapter) (queryHolder, []paramInfo, error) {
+// width is the number of placeholders each element of the parameter expands to.
+func (pi paramInfo) width() int {
+	if len(pi.tupleFields) > 0 {
+		return len(pi.tupleFields)
+	}
+	return 1
+}
+
+func buildFixedQueryAndParamOrder(query string, nameOrderMap map[string]int, funcType reflect.Type, pa ParamAdapter, opts buildOptions) (queryHolder, []paramInfo, error) {
 	var out bytes.Buffer
 	var paramOrder []paramInfo
 
-	isEscaped := false
-	inParam := false
-	var curName bytes.Buffer
+	tokens, err := lexQuery(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	splittable, err := splittableParams(query, tokens)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	source := paramSource(funcType, opts)
 	hasSlice := false
-	for _, v := range query {
-		if isEscaped {
-			out.WriteRune(v)
-			isEscaped = false
+	//emptyCond is written after the text up to end, when an IN predicate is replaced for an empty slice
+	var emptyCond string
+	var emptyEnd int
+	for i, tok := range tokens {
+		if tok.kind == textToken {
+			text := tok.value
+			if emptyCond != "" {
+				out.WriteString(text[:emptyEnd] + "{{else}}" + emptyCond + "{{end}}")
+				text, emptyCond = text[emptyEnd:], ""
+			}
+			//text/template would treat a {{ in the query as the start of an action
+			out.WriteString(strings.Replace(text, "{{", `{{"{{"}}`, -1))
 			continue
 		}
-		switch v {
-		case '\\':
-			isEscaped = true
-		case ':':
-			if inParam {
-				name := curName.String()
-				out.WriteString(fmt.Sprintf(sliceTemplate, name))
-
-				//let's see if this is a slice or not
-				paramPos := nameOrderMap[name]
-				isSlice := false
-				if funcType.In(paramPos).Kind() == reflect.Slice {
-					isSlice = true
-					hasSlice = true
-				}
-				paramOrder = append(paramOrder, paramInfo{name: name, posInParams: paramPos, isSlice: isSlice})
-				curName.Reset()
+		name := tok.value
+
+		if opts.paramTags[name].has("encrypted") && opts.keys == nil {
+			return nil, nil, fmt.Errorf("%w: %s", ErrNoKeyProvider, name)
+		}
+
+		if value, ok := opts.derived[name]; ok {
+			out.WriteString(fmt.Sprintf(sliceTemplate, name))
+			paramOrder = append(paramOrder, paramInfo{name: name, value: value})
+			continue
+		}
+
+		paramPos, ok := nameOrderMap[name]
+		if !ok && source != nil {
+			value, err := source(name)
+			if err != nil {
+				return nil, nil, err
+			}
+			out.WriteString(fmt.Sprintf(sliceTemplate, name))
+			paramOrder = append(paramOrder, paramInfo{name: name, value: value})
+			continue
+		}
+
+		//let's see if this is a slice or not
+		isSlice := false
+		asArray := false
+		var fields []structColumn
+		var fieldTags []profTag
+		if paramType := funcType.In(paramPos); paramType.Kind() == reflect.Slice && !opts.paramTags[name].has("json") {
+			if fields, err = tupleColumns(paramType.Elem(), opts); err != nil {
+				return nil, nil, err
 			}
-			inParam = !inParam
-		default:
-			if !inParam {
-				out.WriteRune(v)
+			fieldTags = tupleTags(fields)
+			if opts.sliceArrays && fields == nil {
+				//[]byte is already a single value to the driver
+				asArray = paramType.Elem().Kind() != reflect.Uint8
 			} else {
-				curName.WriteRune(v)
+				isSlice = true
+				hasSlice = true
+			}
+		}
+		if isSlice && opts.emptySlice == EmptySliceFalse {
+			after := ""
+			if i+1 < len(tokens) && tokens[i+1].kind == textToken {
+				after = tokens[i+1].value
+			}
+			before := out.String()
+			start, end, cond, ok, err := inPredicate(before, after)
+			if err != nil {
+				return nil, nil, err
+			}
+			if ok {
+				out.Reset()
+				out.WriteString(before[:start] + fmt.Sprintf("{{if .%s}}", name) + before[start:])
+				emptyCond, emptyEnd = cond, end
 			}
 		}
+		if fields != nil {
+			out.WriteString(fmt.Sprintf(tupleTemplate, name, len(fields)))
+		} else {
+			out.WriteString(fmt.Sprintf(sliceTemplate, name))
+		}
+		paramOrder = append(paramOrder, paramInfo{name: name, posInParams: paramPos, isSlice: isSlice, asArray: asArray, tupleFields: fields,
+			tag: opts.paramTags[name], tupleTags: fieldTags, splittable: splittable[i]})
 	}
 
 	queryString := out.String()
@@ -142,89 +291,261 @@ func buildFixedQueryAndParamOrder(query string, nameOrderMap map[string]int, fun
 var errType = reflect.TypeOf((*error)(nil)).Elem()
 var errZero = reflect.Zero(errType)
 
-func makeExecutorImplementation(funcType reflect.Type, query queryHolder, paramOrder []paramInfo) (func([]reflect.Value) []reflect.Value, error) {
+func makeExecutorImplementation(funcType reflect.Type, query queryHolder, paramOrder []paramInfo, opts buildOptions) (func([]reflect.Value) []reflect.Value, error) {
+	output, err := buildExecOutput(funcType)
+	if err != nil {
+		return nil, err
+	}
+	saveHooks := buildSaveHooks(funcType)
+
 	return func(args []reflect.Value) []reflect.Value {
 		executor := args[0].Interface().(Executor)
 
-		finalQuery, err := query.finalize(args)
-		if err != nil {
-			var count int64
-			return []reflect.Value{reflect.ValueOf(count), reflect.ValueOf(err).Convert(errType)}
+		if opts.audit != nil {
+			if err := opts.audit(args); err != nil {
+				return output(nil, err)
+			}
+		}
+		if saveHooks != nil {
+			if err := saveHooks(args); err != nil {
+				return output(nil, err)
+			}
 		}
 
-		queryArgs := buildQueryArgs(args, paramOrder)
+		skip, err := checkEmptySlices(args, paramOrder, opts.emptySlice)
+		if skip || err != nil {
+			return output(nil, err)
+		}
 
-		//fmt.Println("I'm execing query", finalQuery, "with args", queryArgs)
-		result, err := executor.Exec(finalQuery, queryArgs...)
-		var count int64
-		if err == nil {
-			count, err = result.RowsAffected()
+		if opts.version != nil {
+			if err := opts.version.checkEntity(args); err != nil {
+				return output(nil, err)
+			}
 		}
-		var errVal reflect.Value
-		if err == nil {
-			errVal = errZero
-		} else {
-			errVal = reflect.ValueOf(err).Convert(errType)
+
+		chunks, err := chunkArgs(args, paramOrder, opts.maxParams)
+		if err != nil {
+			return output(nil, err)
 		}
-		return []reflect.Value{reflect.ValueOf(count), errVal}
 
+		//results are combined across chunks; if a chunk fails, the results so far are returned with the error
+		var results []sql.Result
+		for _, chunkArgs := range chunks {
+			finalQuery, err := query.finalize(chunkArgs)
+			var queryArgs []interface{}
+			if err == nil {
+				queryArgs, err = buildQueryArgs(chunkArgs, paramOrder, opts.keys)
+			}
+			if err == nil {
+
+				//fmt.Println("I'm execing query", finalQuery, "with args", queryArgs)
+				var result sql.Result
+				result, err = executor.Exec(finalQuery, queryArgs...)
+				if err == nil {
+					results = append(results, result)
+				}
+			}
+			if err != nil {
+				result, _ := combineResults(results)
+				return output(result, err)
+			}
+		}
+		result, err := combineResults(results)
+		if err == nil && result != nil && opts.version != nil {
+			err = opts.version.check(args, result)
+		}
+		return output(result, err)
 	}, nil
 }
 
-func makeQuerierImplementation(funcType reflect.Type, query queryHolder, paramOrder []paramInfo) (func([]reflect.Value) []reflect.Value, error) {
+func makeQuerierImplementation(funcType reflect.Type, query queryHolder, paramOrder []paramInfo, opts buildOptions) (func([]reflect.Value) []reflect.Value, error) {
+	if funcType.NumOut() != 2 || funcType.Out(1) != errType {
+		return nil, fmt.Errorf("a Querier func must return a value and an error, not %v", funcType)
+	}
 	firstResult := funcType.Out(0)
 	zeroVal := reflect.Zero(firstResult)
-	returnType := firstResult.Elem()
 
-	rowMapper := mapOneRow
-	if firstResult.Kind() == reflect.Slice {
+	rowMapper, mapper, isMap, err := buildMapRowMapper(firstResult, opts)
+	if err != nil {
+		return nil, err
+	}
+	scalar := false
+	if !isMap {
+		rowMapper, mapper, scalar = buildScalarRowMapper(firstResult)
+	}
+	var returnType reflect.Type
+	if !isMap && !scalar {
+		if kind := firstResult.Kind(); (kind != reflect.Ptr && kind != reflect.Slice) || firstResult.Elem().Kind() != reflect.Struct {
+			return nil, fmt.Errorf("a Querier func must return a pointer to struct, a slice of structs, a map, or a single column value, not %v", firstResult)
+		}
+		returnType = firstResult.Elem()
+		tm := newTypedMapper(returnType, opts)
 		rowMapper = func(rows Rows, mapper Mapper, zeroVal reflect.Value) (reflect.Value, error) {
-			return mapAllRows(returnType, rows, mapper, zeroVal)
+			return tm.mapOne(rows, zeroVal)
+		}
+		if firstResult.Kind() == reflect.Slice {
+			rowMapper = func(rows Rows, mapper Mapper, zeroVal reflect.Value) (reflect.Value, error) {
+				return tm.mapAll(rows, zeroVal)
+			}
+		}
+
+		mapper = buildMapper(returnType, zeroVal, opts)
+
+		plan, err := buildAggregatePlan(returnType, opts)
+		if err != nil {
+			return nil, err
+		}
+		if plan != nil {
+			single := firstResult.Kind() != reflect.Slice
+			rowMapper = func(rows Rows, mapper Mapper, zeroVal reflect.Value) (reflect.Value, error) {
+				return mapAggregatedRows(returnType, plan, opts, single, rows, mapper, zeroVal)
+			}
 		}
 	}
 
-	mapper := buildMapper(returnType, zeroVal)
+	if opts.strict {
+		structType := returnType
+		if isMap && firstResult.Kind() == reflect.Map {
+			structType = indirectType(firstResult.Elem())
+		}
+		if structType != nil && structType.Kind() == reflect.Struct {
+			checker, err := newColumnChecker(structType, opts)
+			if err != nil {
+				return nil, err
+			}
+			rowMapper = strictRowMapper(checker, rowMapper)
+		}
+	}
 
 	return func(args []reflect.Value) []reflect.Value {
 		querier := args[0].Interface().(Querier)
 
-		finalQuery, err := query.finalize(args)
-		if err != nil {
-			return []reflect.Value{zeroVal, reflect.ValueOf(err).Convert(errType)}
+		skip, err := checkEmptySlices(args, paramOrder, opts.emptySlice)
+		if skip || err != nil {
+			return []reflect.Value{zeroVal, errValue(err)}
 		}
 
-		queryArgs := buildQueryArgs(args, paramOrder)
-		//fmt.Println("I'm querying query", finalQuery, "with args", queryArgs)
-		rows, err := querier.Query(finalQuery, queryArgs...)
-
+		chunks, err := chunkArgs(args, paramOrder, opts.maxParams)
 		if err != nil {
 			return []reflect.Value{zeroVal, reflect.ValueOf(err).Convert(errType)}
 		}
 
-		result, err := rowMapper(rows, mapper, zeroVal)
-		rows.Close()
+		//slices and keyed maps are combined across chunks; a single result comes from the first chunk that finds a row
+		result := zeroVal
+		for _, chunkArgs := range chunks {
+			finalQuery, err := query.finalize(chunkArgs)
+			if err != nil {
+				return []reflect.Value{zeroVal, reflect.ValueOf(err).Convert(errType)}
+			}
 
-		if err != nil {
-			return []reflect.Value{result, reflect.ValueOf(err).Convert(errType)}
+			queryArgs, err := buildQueryArgs(chunkArgs, paramOrder, opts.keys)
+			if err != nil {
+				return []reflect.Value{zeroVal, reflect.ValueOf(err).Convert(errType)}
+			}
+			//fmt.Println("I'm querying query", finalQuery, "with args", queryArgs)
+			rows, err := querier.Query(finalQuery, queryArgs...)
+
+			if err != nil {
+				return []reflect.Value{zeroVal, reflect.ValueOf(err).Convert(errType)}
+			}
+
+			chunkResult, err := rowMapper(rows, mapper, zeroVal)
+			rows.Close()
+
+			if err != nil {
+				return []reflect.Value{chunkResult, reflect.ValueOf(err).Convert(errType)}
+			}
+
+			if firstResult.Kind() == reflect.Slice {
+				result = reflect.AppendSlice(result, chunkResult)
+			} else if opts.keyCol != "" && firstResult.Kind() == reflect.Map {
+				result = mergeMaps(result, chunkResult)
+			} else if scalar && firstResult.Kind() != reflect.Ptr || !chunkResult.IsNil() {
+				result = chunkResult
+				break
+			}
+		}
+		if result.Kind() == reflect.Slice && result.Len() == 0 {
+			result = zeroVal
 		}
 
 		return []reflect.Value{result, errZero}
 	}, nil
 }
 
-func buildQueryArgs(funcArgs []reflect.Value, paramOrder []paramInfo) []interface{} {
+func errValue(err error) reflect.Value {
+	if err == nil {
+		return errZero
+	}
+	return reflect.ValueOf(err).Convert(errType)
+}
+
+// checkEmptySlices looks for slice parameters with no elements. It returns true if the query
+// shouldn't be run, and an error if that should be reported to the caller.
+func checkEmptySlices(funcArgs []reflect.Value, paramOrder []paramInfo, mode EmptySliceMode) (bool, error) {
+	for _, v := range paramOrder {
+		if !v.isSlice || funcArgs[v.posInParams].Len() > 0 {
+			continue
+		}
+		switch mode {
+		case EmptySliceError:
+			return true, fmt.Errorf("%w: %s", ErrEmptySlice, v.name)
+		case EmptySliceSkip:
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func buildQueryArgs(funcArgs []reflect.Value, paramOrder []paramInfo, keys KeyProvider) ([]interface{}, error) {
 	out := []interface{}{}
 	for _, v := range paramOrder {
 		if v.isSlice {
 			curSlice := funcArgs[v.posInParams]
 			for i := 0; i < curSlice.Len(); i++ {
-				out = append(out, curSlice.Index(i).Interface())
+				if v.tupleFields == nil {
+					arg, err := encodeValue(curSlice.Index(i), v.tag, keys)
+					if err != nil {
+						return nil, err
+					}
+					out = append(out, arg)
+					continue
+				}
+				for k, col := range v.tupleFields {
+					//a nil pointer in the slice, or on the way to an embedded field, binds NULL
+					field := fieldByIndexNil(curSlice.Index(i), col.info.index)
+					if !field.IsValid() {
+						out = append(out, nil)
+						continue
+					}
+					arg, err := encodeValue(field, v.tupleTags[k], keys)
+					if err != nil {
+						return nil, err
+					}
+					out = append(out, arg)
+				}
 			}
+		} else if v.asArray {
+			arg, err := encodeArray(funcArgs[v.posInParams], v.tag, keys)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, arg)
+		} else if v.value != nil {
+			arg, err := v.value(funcArgs)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, arg)
 		} else {
-			out = append(out, funcArgs[v.posInParams].Interface())
+			arg, err := encodeValue(funcArgs[v.posInParams], v.tag, keys)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, arg)
 		}
 	}
-	return out
+	return out, nil
 }
 
 func mapOneRow(rows Rows, mapper Mapper, zeroVal reflect.Value) (reflect.Value, error) {
@@ -279,7 +600,10 @@ func mapAllRows(returnType reflect.Type, rows Rows, mapper Mapper, zeroVal refle
 		if err != nil {
 			return zeroVal, err
 		}
-		outSlice = reflect.Append(outSlice, curVal.Elem())
+		if curVal.Type() != returnType {
+			curVal = curVal.Elem()
+		}
+		outSlice = reflect.Append(outSlice, curVal)
 	}
 	if err := rows.Err(); err != nil {
 		return zeroVal, err
@@ -295,25 +619,33 @@ type Mapper func(cols []string, vals []interface{}) (reflect.Value, error)
 type fieldInfo struct {
 	name      string
 	fieldType reflect.Type
-	pos       int
+	index     []int
+	tag       profTag
+	//keys decrypts the column, if the field has the encrypted option
+	keys KeyProvider
+	enum bool
 }
 
-func buildMapper(returnType reflect.Type, zeroVal reflect.Value) Mapper {
+func buildMapper(returnType reflect.Type, zeroVal reflect.Value, opts buildOptions) Mapper {
+	return buildPrefixedMapper(returnType, zeroVal, "", opts)
+}
+
+// buildPrefixedMapper builds a Mapper for a struct whose columns all start with prefix.
+func buildPrefixedMapper(returnType reflect.Type, zeroVal reflect.Value, prefix string, opts buildOptions) Mapper {
+	return newStructMapper(returnType, zeroVal, prefix, opts, hasAfterLoad(returnType))
+}
+
+// newStructMapper builds a Mapper that calls AfterLoad on each struct it fills if afterLoad is true.
+func newStructMapper(returnType reflect.Type, zeroVal reflect.Value, prefix string, opts buildOptions, afterLoad bool) Mapper {
 	//build map of col names to field names (makes this 2N instead of N^2)
-	colFieldMap := map[string]fieldInfo{}
-	for i := 0; i < returnType.NumField(); i++ {
-		sf := returnType.Field(i)
-		tagVal := sf.Tag.Get("prof")
-		colFieldMap[tagVal] = fieldInfo{
-			name:      sf.Name,
-			fieldType: sf.Type,
-			pos:       i,
-		}
-	}
+	colFieldMap := buildColFieldMap(returnType, prefix, opts)
 
 	return func(cols []string, vals []interface{}) (reflect.Value, error) {
 		returnVal := reflect.New(returnType)
-		err := populateReturnVal(returnVal, cols, vals, colFieldMap)
+		err := populateReturnVal(returnVal, cols, vals, colFieldMap, opts)
+		if err == nil && afterLoad {
+			err = runAfterLoad(returnVal.Elem())
+		}
 		if err != nil {
 			return zeroVal, err
 		}
@@ -321,16 +653,138 @@ func buildMapper(returnType reflect.Type, zeroVal reflect.Value) Mapper {
 	}
 }
 
-func populateReturnVal(returnVal reflect.Value, cols []string, vals []interface{}, colFieldMap map[string]fieldInfo) error {
+// buildColFieldMap finds the column for each field of returnType. The fields of an embedded struct
+// are treated as fields of returnType, and a struct field with a prof tag has its fields mapped from
+// columns that start with the tag's name, so Addr Address `prof:"addr_"` fills Addr.City from addr_city.
+// Fields tagged with prof:"-" are skipped. Fields without a prof tag get their column (or prefix) from
+// the NamingStrategy, and are skipped if there isn't one.
+func buildColFieldMap(returnType reflect.Type, prefix string, opts buildOptions) map[string]fieldInfo {
+	colFieldMap := map[string]fieldInfo{}
+	addFields(colFieldMap, returnType, prefix, nil, opts)
+	return colFieldMap
+}
+
+// structColumn is a column of a struct, and the field it maps to.
+type structColumn struct {
+	name string
+	info fieldInfo
+}
+
+// structColumns returns the columns of entityType, as found by buildColFieldMap, in the order their fields are declared.
+func structColumns(entityType reflect.Type, opts buildOptions) []structColumn {
+	var out []structColumn
+	for k, v := range buildColFieldMap(entityType, "", opts) {
+		out = append(out, structColumn{name: k, info: v})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i].info.index, out[j].info.index
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	return out
+}
+
+func addFields(colFieldMap map[string]fieldInfo, curType reflect.Type, prefix string, parentIndex []int, opts buildOptions) {
+	for i := 0; i < curType.NumField(); i++ {
+		sf := curType.Field(i)
+		tag := parseProfTag(sf)
+		if tag.name == "-" || tag.has("many") {
+			//many fields are filled in by mapAggregatedRows
+			continue
+		}
+		index := append(append([]int{}, parentIndex...), i)
+
+		structType := sf.Type
+		if structType.Kind() == reflect.Ptr {
+			structType = structType.Elem()
+		}
+		//a json field is a single column, whatever its type
+		composite := isCompositeStruct(structType) && !tag.has("json")
+		if sf.Anonymous && sf.PkgPath != "" && sf.Type.Kind() == reflect.Ptr {
+			//as with encoding/json, a pointer to an unexported struct type can't be allocated, so it is skipped
+			continue
+		}
+		if sf.Anonymous && tag.name == "" && composite {
+			addFields(colFieldMap, structType, prefix, index, opts)
+			continue
+		}
+		if sf.PkgPath != "" {
+			//unexported
+			continue
+		}
+		name := tag.name
+		if name == "" {
+			if name = opts.fieldColumn(sf.Name); name == "" {
+				continue
+			}
+			if composite {
+				name += "_"
+			}
+		}
+		if composite {
+			addFields(colFieldMap, structType, prefix+name, index, opts)
+			continue
+		}
+
+		//as with Go's own field promotion, a shallower field hides a deeper one with the same name
+		col := opts.columnKey(prefix + name)
+		if cur, ok := colFieldMap[col]; ok && len(cur.index) < len(index) {
+			continue
+		}
+		colFieldMap[col] = fieldInfo{
+			name:      sf.Name,
+			fieldType: sf.Type,
+			index:     index,
+			tag:       tag,
+			keys:      opts.keys,
+			enum:      isEnum(sf.Type),
+		}
+	}
+}
+
+// fieldByIndexAlloc is like FieldByIndex, but allocates any nil struct pointers along the way.
+func fieldByIndexAlloc(val reflect.Value, index []int) reflect.Value {
+	for k, v := range index {
+		if k > 0 && val.Kind() == reflect.Ptr {
+			if val.IsNil() {
+				val.Set(reflect.New(val.Type().Elem()))
+			}
+			val = val.Elem()
+		}
+		val = val.Field(v)
+	}
+	return val
+}
+
+// fieldByIndexNil is like FieldByIndex, but returns the zero Value if it reaches a nil pointer.
+func fieldByIndexNil(val reflect.Value, index []int) reflect.Value {
+	for _, v := range index {
+		if val.Kind() == reflect.Ptr {
+			if val.IsNil() {
+				return reflect.Value{}
+			}
+			val = val.Elem()
+		}
+		val = val.Field(v)
+	}
+	return val
+}
+
+func populateReturnVal(returnVal reflect.Value, cols []string, vals []interface{}, colFieldMap map[string]fieldInfo, opts buildOptions) error {
 	val := returnVal.Elem()
 	for k, v := range cols {
-		if sf, ok := colFieldMap[v]; ok {
-			curVal := vals[k]
-			rv := reflect.ValueOf(curVal)
-			if rv.Elem().Elem().Type().ConvertibleTo(sf.fieldType) {
-				val.Field(sf.pos).Set(rv.Elem().Elem().Convert(sf.fieldType))
-			} else {
-				return fmt.Errorf("Unable to assign value %v of type %v to struct field %s of type %v", rv.Elem().Elem(), rv.Elem().Elem().Type(), sf.name, sf.fieldType)
+		if sf, ok := colFieldMap[opts.columnKey(v)]; ok {
+			curVal := *vals[k].(*interface{})
+			if curVal == nil {
+				//NULL leaves the field at its zero value
+				continue
+			}
+			if err := assignValue(fieldByIndexAlloc(val, sf.index), curVal, &sf); err != nil {
+				return err
 			}
 		}
 	}
@@ -359,7 +813,8 @@ func (tq templateQueryHolder) finalize(args []reflect.Value) (string, error) {
 }
 
 func doFinalize(queryString string, paramOrder []paramInfo, pa ParamAdapter, args []reflect.Value) (string, error) {
-	temp, err := template.New("query").Funcs(template.FuncMap{"join": joinFactory(1, pa)}).Parse(queryString)
+	join := joinFactory(1, pa)
+	temp, err := template.New("query").Funcs(template.FuncMap{"join": join, "tuple": tupleFactory(join)}).Parse(queryString)
 	if err != nil {
 		return "", err
 	}
@@ -382,10 +837,15 @@ func doFinalize(queryString string, paramOrder []paramInfo, pa ParamAdapter, arg
 
 const (
 	sliceTemplate = `{{.%s | join}}`
+	tupleTemplate = `{{.%s | tuple %d}}`
 )
 
 func joinFactory(startPos int, paramAdapter ParamAdapter) func(int) string {
 	return func(total int) string {
+		if total == 0 {
+			//only reached for EmptySliceFalse outside of an IN predicate; an empty list isn't valid SQL
+			return "NULL"
+		}
 		var b bytes.Buffer
 		for i := 0; i < total; i++ {
 			if i > 0 {
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+type rowMapper func(rows Rows, mapper Mapper, zeroVal reflect.Value) (reflect.Value, error)
+
+// isColumnMap reports if t is a map[string]interface{}, which holds a row keyed by column name.
+func isColumnMap(t reflect.Type) bool {
+	return t.Kind() == reflect.Map && t.Key().Kind() == reflect.String && t.Elem().Kind() == reflect.Interface && t.Elem().NumMethod() == 0
+}
+
+// buildMapRowMapper handles Querier funcs that return maps:
+//
+//	map[string]interface{}, the first row keyed by column name
+//	[]map[string]interface{}, every row keyed by column name
+//	map[K]V, where V is a struct or a pointer to struct, keyed by the column named in the prokey tag
+//
+// The last result is false if firstResult isn't a map or a slice of maps.
+func buildMapRowMapper(firstResult reflect.Type, opts buildOptions) (rowMapper, Mapper, bool, error) {
+	switch {
+	case isColumnMap(firstResult):
+		return mapOneRow, columnMapper(firstResult), true, nil
+	case firstResult.Kind() == reflect.Slice && isColumnMap(firstResult.Elem()):
+		returnType := firstResult.Elem()
+		return func(rows Rows, mapper Mapper, zeroVal reflect.Value) (reflect.Value, error) {
+			return mapAllRows(returnType, rows, mapper, zeroVal)
+		}, columnMapper(returnType), true, nil
+	case firstResult.Kind() != reflect.Map:
+		return nil, nil, false, nil
+	}
+
+	keyCol := opts.keyCol
+	if keyCol == "" {
+		return nil, nil, true, fmt.Errorf("a func returning %v needs a prokey tag naming the key column", firstResult)
+	}
+	structType := indirectType(firstResult.Elem())
+	if structType.Kind() != reflect.Struct {
+		return nil, nil, true, fmt.Errorf("a func returning %v must have a struct or pointer to struct as the map value", firstResult)
+	}
+	mapper := buildMapper(structType, reflect.Zero(reflect.PtrTo(structType)), opts)
+	return func(rows Rows, mapper Mapper, zeroVal reflect.Value) (reflect.Value, error) {
+		return mapKeyedRows(firstResult, keyCol, opts, rows, mapper, zeroVal)
+	}, mapper, true, nil
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+	return t
+}
+
+func columnMapper(mapType reflect.Type) Mapper {
+	return func(cols []string, vals []interface{}) (reflect.Value, error) {
+		out := reflect.MakeMapWithSize(mapType, len(cols))
+		for k, v := range cols {
+			val := normalizeValue(*vals[k].(*interface{}))
+			elem := reflect.Zero(mapType.Elem())
+			if val != nil {
+				elem = reflect.ValueOf(val)
+			}
+			out.SetMapIndex(reflect.ValueOf(v).Convert(mapType.Key()), elem)
+		}
+		return out, nil
+	}
+}
+
+// normalizeValue converts the values drivers return into the types a caller would expect in a map.
+// Text columns often come back as []byte, and are turned into strings.
+func normalizeValue(val interface{}) interface{} {
+	if b, ok := val.([]byte); ok {
+		return string(b)
+	}
+	return val
+}
+
+func mapKeyedRows(mapType reflect.Type, keyCol string, opts buildOptions, rows Rows, mapper Mapper, zeroVal reflect.Value) (reflect.Value, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return zeroVal, err
+	}
+	keyPos := -1
+	for k, v := range cols {
+		if opts.columnKey(v) == opts.columnKey(keyCol) {
+			keyPos = k
+		}
+	}
+	if keyPos == -1 {
+		return zeroVal, fmt.Errorf("key column %s isn't in the query results", keyCol)
+	}
+
+	out := reflect.MakeMap(mapType)
+	for rows.Next() {
+		vals := make([]interface{}, len(cols))
+		for i := 0; i < len(vals); i++ {
+			vals[i] = new(interface{})
+		}
+		err = rows.Scan(vals...)
+		if err != nil {
+			return zeroVal, err
+		}
+		curVal, err := mapper(cols, vals)
+		if err != nil {
+			return zeroVal, err
+		}
+		if mapType.Elem().Kind() != reflect.Ptr {
+			curVal = curVal.Elem()
+		}
+
+		key, err := convertKey(normalizeValue(*vals[keyPos].(*interface{})), mapType.Key())
+		if err != nil {
+			return zeroVal, fmt.Errorf("Unable to use value of key column %s as a map key: %w", keyCol, err)
+		}
+		out.SetMapIndex(key, curVal)
+	}
+	if err := rows.Err(); err != nil {
+		return zeroVal, err
+	}
+	if out.Len() == 0 {
+		return zeroVal, nil
+	}
+	return out, nil
+}
+
+// mergeMaps adds the entries in from to into, which is created if it is nil.
+func mergeMaps(into reflect.Value, from reflect.Value) reflect.Value {
+	if from.IsNil() {
+		return into
+	}
+	if into.IsNil() {
+		return from
+	}
+	iter := from.MapRange()
+	for iter.Next() {
+		into.SetMapIndex(iter.Key(), iter.Value())
+	}
+	return into
+}
+
+// convertKey converts the value of a key column to the map's key type. Numbers are formatted as decimal
+// strings for string keys, and strings are parsed for numeric keys, rather than converted rune by rune.
+func convertKey(val interface{}, keyType reflect.Type) (reflect.Value, error) {
+	rv := reflect.ValueOf(val)
+	if !rv.IsValid() {
+		return reflect.Value{}, errors.New("NULL can't be a map key")
+	}
+	switch {
+	case keyType.Kind() == reflect.String && isIntKind(rv.Kind()):
+		rv = reflect.ValueOf(strconv.FormatInt(rv.Int(), 10))
+	case isIntKind(keyType.Kind()) && rv.Kind() == reflect.String:
+		i, err := strconv.ParseInt(rv.String(), 10, 64)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		rv = reflect.ValueOf(i)
+	}
+	if !rv.Type().ConvertibleTo(keyType) || (keyType.Kind() == reflect.String && rv.Kind() != reflect.String) {
+		return reflect.Value{}, fmt.Errorf("value %v of type %v can't be a key of type %v", rv, rv.Type(), keyType)
+	}
+	return rv.Convert(keyType), nil
+}
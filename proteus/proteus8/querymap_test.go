@@ -0,0 +1,112 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+type mapDao struct {
+	Report  func(q Querier, age int) (map[string]interface{}, error) `proq:"SELECT name, count(*) AS total FROM PERSON WHERE age > :age: GROUP BY name" prop:"age"`
+	Reports func(q Querier) ([]map[string]interface{}, error)        `proq:"SELECT name, count(*) AS total FROM PERSON GROUP BY name"`
+	ByID    func(q Querier) (map[int]Person, error)                  `proq:"SELECT * FROM PERSON" prokey:"id"`
+	ByName  func(q Querier, ids []int) (map[string]*Person, error)   `proq:"SELECT * FROM PERSON WHERE id IN (:ids:)" prop:"ids" prokey:"name"`
+}
+
+func TestColumnMaps(t *testing.T) {
+	var dao mapDao
+	if err := Build(&dao, Postgres); err != nil {
+		t.Fatal(err)
+	}
+	fw := &fakeWrapper{
+		cols: []string{"name", "total"},
+		rows: [][]interface{}{{[]byte("Fred"), int64(2)}, {"Bob", nil}},
+	}
+	report, err := dao.Report(fw, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[string]interface{}{"name": "Fred", "total": int64(2)}; !reflect.DeepEqual(report, expected) {
+		t.Errorf("expected %v, got %v", expected, report)
+	}
+
+	reports, err := dao.Reports(fw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []map[string]interface{}{{"name": "Fred", "total": int64(2)}, {"name": "Bob", "total": nil}}
+	if !reflect.DeepEqual(reports, expected) {
+		t.Errorf("expected %v, got %v", expected, reports)
+	}
+
+	fw = &fakeWrapper{cols: []string{"name", "total"}}
+	if report, err := dao.Report(fw, 10); err != nil || report != nil {
+		t.Errorf("expected nil with no rows, got %v, %v", report, err)
+	}
+}
+
+func TestKeyedMaps(t *testing.T) {
+	var dao mapDao
+	if err := Build(&dao, Postgres, WithMaxParams(1)); err != nil {
+		t.Fatal(err)
+	}
+	fw := &fakeWrapper{
+		cols: []string{"id", "name", "age"},
+		rows: [][]interface{}{{int64(1), "Fred", int64(20)}, {int64(2), "Bob", int64(50)}},
+	}
+	byID, err := dao.ByID(fw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[int]Person{1: {1, "Fred", 20}, 2: {2, "Bob", 50}}; !reflect.DeepEqual(byID, expected) {
+		t.Errorf("expected %v, got %v", expected, byID)
+	}
+
+	byName, err := dao.ByName(fw, []int{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fw.queries) != 3 || len(byName) != 2 || byName["Bob"].Id != 2 {
+		t.Errorf("expected a map merged across chunks, got %v from %v", byName, fw.queries)
+	}
+
+	var noKey struct {
+		ByID func(q Querier) (map[int]Person, error) `proq:"SELECT * FROM PERSON"`
+	}
+	if err := Build(&noKey, Postgres); err == nil {
+		t.Error("expected a keyed map without a prokey tag to be rejected")
+	}
+
+	var missingCol struct {
+		ByID func(q Querier) (map[int]Person, error) `proq:"SELECT * FROM PERSON" prokey:"person_id"`
+	}
+	if err := Build(&missingCol, Postgres); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := missingCol.ByID(fw); err == nil {
+		t.Error("expected an error when the key column isn't returned")
+	}
+}
+
+func TestKeyConversion(t *testing.T) {
+	var dao struct {
+		ByID   func(q Querier) (map[string]Person, error) `proq:"SELECT * FROM PERSON" prokey:"id"`
+		ByText func(q Querier) (map[int]Person, error)    `proq:"SELECT * FROM PERSON" prokey:"NAME"`
+	}
+	if err := Build(&dao, Postgres, WithCaseInsensitiveColumns()); err != nil {
+		t.Fatal(err)
+	}
+	fw := &fakeWrapper{cols: []string{"id", "name", "age"}, rows: [][]interface{}{{int64(65), "Fred", int64(20)}}}
+	byID, err := dao.ByID(fw)
+	if err != nil || byID["65"].Name != "Fred" {
+		t.Errorf("expected the key 65, got %v, %v", byID, err)
+	}
+	fw.rows = [][]interface{}{{int64(1), []byte("65"), int64(20)}}
+	byText, err := dao.ByText(fw)
+	if err != nil || byText[65].Id != 1 {
+		t.Errorf("expected the key 65, got %v, %v", byText, err)
+	}
+	fw.rows = [][]interface{}{{1.5, "Fred", int64(20)}}
+	if _, err := dao.ByID(fw); err == nil {
+		t.Error("expected an error for a float key")
+	}
+}
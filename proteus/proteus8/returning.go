@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// returningQuery adds a RETURNING clause for the generated column to an INSERT or UPDATE.
+// A pointer to struct result gets the whole row back. The clause goes after the last of the SQL,
+// before any trailing semicolon or comment.
+func returningQuery(query string, funcType reflect.Type, returnCol string) (string, error) {
+	cols := returnCol
+	if funcType.Out(0).Kind() == reflect.Ptr {
+		cols = "*"
+	}
+	mask, err := maskQuery(query)
+	if err != nil {
+		return "", err
+	}
+	in := []rune(query)
+	end := statementEnd(mask)
+	return string(in[:end]) + " RETURNING " + cols + string(in[end:]), nil
+}
+
+func isIntKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+// makeReturningImplementation builds an Executor func that returns the generated id (or the row
+// holding it) instead of the number of rows affected. With WithReturning the query runs through
+// Query with a RETURNING clause; otherwise it runs through Exec and the id comes from LastInsertId.
+// The call always runs as a single query, since it returns a single id; WithMaxParams doesn't split it.
+func makeReturningImplementation(funcType reflect.Type, query queryHolder, paramOrder []paramInfo, opts buildOptions) (func([]reflect.Value) []reflect.Value, error) {
+	if funcType.NumOut() != 2 || funcType.Out(1) != errType {
+		return nil, errors.New("a func with a proreturn tag must return a value and an error")
+	}
+	if opts.returning && !funcType.In(0).Implements(qType) {
+		return nil, fmt.Errorf("with WithReturning, a func with a proreturn tag needs an Executor that is also a Querier, not %v", funcType.In(0))
+	}
+	firstResult := funcType.Out(0)
+	zeroVal := reflect.Zero(firstResult)
+
+	var mapper Mapper
+	var checker *columnChecker
+	var idField []int
+	switch {
+	case isIntKind(firstResult.Kind()):
+	case firstResult.Kind() == reflect.Ptr && firstResult.Elem().Kind() == reflect.Struct:
+		returnType := firstResult.Elem()
+		mapper = buildMapper(returnType, zeroVal, opts)
+		if opts.strict {
+			var err error
+			if checker, err = newColumnChecker(returnType, opts); err != nil {
+				return nil, err
+			}
+		}
+		if sf, ok := buildColFieldMap(returnType, "", opts)[opts.columnKey(opts.returnCol)]; ok && isIntKind(sf.fieldType.Kind()) {
+			idField = sf.index
+		}
+		if idField == nil && !opts.returning {
+			return nil, fmt.Errorf("%v has no integer field for column %s to hold the LastInsertId", returnType, opts.returnCol)
+		}
+	default:
+		return nil, fmt.Errorf("a func with a proreturn tag must return an integer or a pointer to struct, not %v", firstResult)
+	}
+
+	saveHooks := buildSaveHooks(funcType)
+
+	return func(args []reflect.Value) []reflect.Value {
+		if opts.audit != nil {
+			if err := opts.audit(args); err != nil {
+				return []reflect.Value{zeroVal, reflect.ValueOf(err).Convert(errType)}
+			}
+		}
+		if saveHooks != nil {
+			if err := saveHooks(args); err != nil {
+				return []reflect.Value{zeroVal, reflect.ValueOf(err).Convert(errType)}
+			}
+		}
+
+		skip, err := checkEmptySlices(args, paramOrder, opts.emptySlice)
+		if skip || err != nil {
+			return []reflect.Value{zeroVal, errValue(err)}
+		}
+
+		finalQuery, err := query.finalize(args)
+		if err != nil {
+			return []reflect.Value{zeroVal, reflect.ValueOf(err).Convert(errType)}
+		}
+		queryArgs, err := buildQueryArgs(args, paramOrder, opts.keys)
+		if err != nil {
+			return []reflect.Value{zeroVal, reflect.ValueOf(err).Convert(errType)}
+		}
+
+		if !opts.returning {
+			result, err := args[0].Interface().(Executor).Exec(finalQuery, queryArgs...)
+			if err != nil {
+				return []reflect.Value{zeroVal, reflect.ValueOf(err).Convert(errType)}
+			}
+			id, err := result.LastInsertId()
+			if err != nil {
+				return []reflect.Value{zeroVal, reflect.ValueOf(err).Convert(errType)}
+			}
+			if mapper == nil {
+				return []reflect.Value{reflect.ValueOf(id).Convert(firstResult), errZero}
+			}
+			out := reflect.New(firstResult.Elem())
+			idVal := fieldByIndexAlloc(out.Elem(), idField)
+			idVal.Set(reflect.ValueOf(id).Convert(idVal.Type()))
+			return []reflect.Value{out, errZero}
+		}
+
+		rows, err := args[0].Interface().(Querier).Query(finalQuery, queryArgs...)
+		if err != nil {
+			return []reflect.Value{zeroVal, reflect.ValueOf(err).Convert(errType)}
+		}
+		var result reflect.Value
+		if checker != nil {
+			result, err = strictRowMapper(checker, mapOneRow)(rows, mapper, zeroVal)
+		} else if mapper != nil {
+			result, err = mapOneRow(rows, mapper, zeroVal)
+		} else {
+			result, err = scanOneValue(rows, firstResult, zeroVal)
+		}
+		rows.Close()
+		return []reflect.Value{result, errValue(err)}
+	}, nil
+}
+
+// scanOneValue reads the single column of the first row into a value of returnType.
+func scanOneValue(rows Rows, returnType reflect.Type, zeroVal reflect.Value) (reflect.Value, error) {
+	if !rows.Next() {
+		return zeroVal, rows.Err()
+	}
+	val := new(interface{})
+	if err := rows.Scan(val); err != nil {
+		return zeroVal, err
+	}
+	rv := reflect.ValueOf(*val)
+	if !rv.IsValid() || !rv.Type().ConvertibleTo(returnType) {
+		return zeroVal, fmt.Errorf("Unable to assign value %v to return type %v", *val, returnType)
+	}
+	return rv.Convert(returnType), nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+)
+
+type returningDao struct {
+	CreateID     func(w Wrapper, name string, age int) (int64, error)   `proq:"INSERT INTO PERSON(name, age) VALUES(:name:, :age:); -- add one" prop:"name,age" proreturn:"id"`
+	CreatePerson func(w Wrapper, name string, age int) (*Person, error) `proq:"INSERT INTO PERSON(name, age) VALUES(:name:, :age:)" prop:"name,age" proreturn:"id"`
+}
+
+func TestReturning(t *testing.T) {
+	var dao returningDao
+	if err := Build(&dao, Postgres, WithReturning()); err != nil {
+		t.Fatal(err)
+	}
+
+	fw := &fakeWrapper{cols: []string{"id"}, rows: [][]interface{}{{int64(7)}}}
+	id, err := dao.CreateID(fw, "Fred", 20)
+	if err != nil || id != 7 {
+		t.Errorf("expected id 7, got %v, %v", id, err)
+	}
+	if expected := "INSERT INTO PERSON(name, age) VALUES($1, $2) RETURNING id; -- add one"; fw.queries[0] != expected {
+		t.Errorf("expected %q, got %q", expected, fw.queries[0])
+	}
+
+	fw = &fakeWrapper{cols: []string{"id", "name", "age"}, rows: [][]interface{}{{int64(8), "Bob", int64(50)}}}
+	person, err := dao.CreatePerson(fw, "Bob", 50)
+	if err != nil || person == nil || *person != (Person{Id: 8, Name: "Bob", Age: 50}) {
+		t.Errorf("expected the inserted row, got %v, %v", person, err)
+	}
+	if expected := "INSERT INTO PERSON(name, age) VALUES($1, $2) RETURNING *"; fw.queries[0] != expected {
+		t.Errorf("expected %q, got %q", expected, fw.queries[0])
+	}
+}
+
+func TestReturningLastInsertId(t *testing.T) {
+	var dao returningDao
+	if err := Build(&dao, MySQL); err != nil {
+		t.Fatal(err)
+	}
+
+	fw := &fakeWrapper{result: fakeResult{lastInsertId: 12, rowsAffected: 1}}
+	id, err := dao.CreateID(fw, "Fred", 20)
+	if err != nil || id != 12 {
+		t.Errorf("expected id 12, got %v, %v", id, err)
+	}
+	if expected := "INSERT INTO PERSON(name, age) VALUES(?, ?); -- add one"; fw.queries[0] != expected {
+		t.Errorf("expected %q, got %q", expected, fw.queries[0])
+	}
+
+	person, err := dao.CreatePerson(fw, "Bob", 50)
+	if err != nil || person == nil || person.Id != 12 {
+		t.Errorf("expected a person with id 12, got %v, %v", person, err)
+	}
+}
+
+func TestReturningInvalid(t *testing.T) {
+	var badType struct {
+		Create func(w Wrapper, name string) (string, error) `proq:"INSERT INTO PERSON(name) VALUES(:name:)" prop:"name" proreturn:"id"`
+	}
+	if err := Build(&badType, Postgres, WithReturning()); err == nil {
+		t.Error("expected a string result to be rejected")
+	}
+
+	var noField struct {
+		Create func(e Executor, name string) (*Person, error) `proq:"INSERT INTO PERSON(name) VALUES(:name:)" prop:"name" proreturn:"person_id"`
+	}
+	if err := Build(&noField, MySQL); err == nil {
+		t.Error("expected a struct without the id field to be rejected")
+	}
+
+	var noQuerier struct {
+		Create func(e Executor, name string) (int64, error) `proq:"INSERT INTO PERSON(name) VALUES(:name:)" prop:"name" proreturn:"id"`
+	}
+	if err := Build(&noQuerier, Postgres, WithReturning()); err == nil {
+		t.Error("expected an Executor that isn't a Querier to be rejected")
+	}
+	if err := Build(&noQuerier, MySQL); err != nil {
+		t.Errorf("expected an Executor to work without WithReturning, got %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// findKeyword returns the position of the first keyword in mask at or after from, or -1 if there isn't one.
+// Only keywords outside of parentheses count, so a WHERE in a subquery isn't found. mask comes from maskQuery.
+func findKeyword(mask []rune, keyword string, from int) int {
+	depth := 0
+	for i := 0; i < len(mask); i++ {
+		switch mask[i] {
+		case '(':
+			depth++
+			continue
+		case ')':
+			depth--
+			continue
+		}
+		if depth != 0 || i < from || i+len(keyword) > len(mask) {
+			continue
+		}
+		if i > 0 && isIdentRune(mask[i-1]) {
+			continue
+		}
+		if i+len(keyword) < len(mask) && isIdentRune(mask[i+len(keyword)]) {
+			continue
+		}
+		if strings.EqualFold(string(mask[i:i+len(keyword)]), keyword) {
+			return i
+		}
+	}
+	return -1
+}
+
+// statementKind returns the first word of the statement, in upper case.
+func statementKind(mask []rune) string {
+	start := 0
+	for start < len(mask) && unicode.IsSpace(mask[start]) {
+		start++
+	}
+	end := start
+	for end < len(mask) && isIdentRune(mask[end]) {
+		end++
+	}
+	return strings.ToUpper(string(mask[start:end]))
+}
+
+// statementEnd returns the position after the last rune of the statement that isn't whitespace or a semicolon.
+func statementEnd(mask []rune) int {
+	end := len(mask)
+	for end > 0 && (unicode.IsSpace(mask[end-1]) || mask[end-1] == ';') {
+		end--
+	}
+	return end
+}
+
+// addCondition ANDs cond onto the WHERE clause of a statement, or adds a WHERE clause if there isn't one.
+// The clause ends at the first of the keywords in ends that follows it, or at the end of the statement.
+func addCondition(query string, mask []rune, cond string, ends ...string) string {
+	in := []rune(query)
+	start := 0
+	where := findKeyword(mask, "WHERE", 0)
+	if where != -1 {
+		start = where + len("WHERE")
+	}
+	end := statementEnd(mask)
+	for _, v := range ends {
+		if pos := findKeyword(mask, v, start); pos != -1 && pos < end {
+			end = pos
+		}
+	}
+	var b strings.Builder
+	if where == -1 {
+		b.WriteString(strings.TrimRightFunc(string(in[:end]), unicode.IsSpace))
+		b.WriteString(" WHERE " + cond)
+	} else {
+		b.WriteString(string(in[:start]))
+		b.WriteString(" (" + strings.TrimSpace(string(in[start:end])) + ") AND " + cond)
+	}
+	if end < len(in) && !unicode.IsSpace(in[end]) && in[end] != ';' {
+		b.WriteString(" ")
+	}
+	b.WriteString(string(in[end:]))
+	return b.String()
+}
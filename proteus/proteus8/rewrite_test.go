@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestAddCondition(t *testing.T) {
+	data := []struct {
+		query    string
+		expected string
+	}{
+		{"SELECT * FROM PERSON", "SELECT * FROM PERSON WHERE deleted_at IS NULL"},
+		{"SELECT * FROM PERSON;", "SELECT * FROM PERSON WHERE deleted_at IS NULL;"},
+		{"SELECT * FROM PERSON WHERE id = :id: OR name = :name:", "SELECT * FROM PERSON WHERE (id = :id: OR name = :name:) AND deleted_at IS NULL"},
+		{"SELECT * FROM PERSON ORDER BY name", "SELECT * FROM PERSON WHERE deleted_at IS NULL ORDER BY name"},
+		{"SELECT * FROM PERSON WHERE id IN (SELECT id FROM T WHERE x = 1) LIMIT 5", "SELECT * FROM PERSON WHERE (id IN (SELECT id FROM T WHERE x = 1)) AND deleted_at IS NULL LIMIT 5"},
+		{"SELECT * FROM PERSON WHERE name = 'ORDER BY' -- WHERE\n", "SELECT * FROM PERSON WHERE (name = 'ORDER BY') AND deleted_at IS NULL -- WHERE\n"},
+		{`SELECT "where" FROM PERSON`, `SELECT "where" FROM PERSON WHERE deleted_at IS NULL`},
+		{"SELECT * FROM PERSON WHERE age > :limit:", "SELECT * FROM PERSON WHERE (age > :limit:) AND deleted_at IS NULL"},
+		{"SELECT * FROM PERSON WHERE name = :where: ORDER BY :order:", "SELECT * FROM PERSON WHERE (name = :where:) AND deleted_at IS NULL ORDER BY :order:"},
+	}
+	for _, v := range data {
+		mask, err := maskQuery(v.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(mask) != len([]rune(v.query)) {
+			t.Errorf("%q: mask %q has a different length", v.query, string(mask))
+		}
+		out := addCondition(v.query, mask, "deleted_at IS NULL", "ORDER", "LIMIT")
+		if out != v.expected {
+			t.Errorf("%q: expected %q, got %q", v.query, v.expected, out)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// isScalar reports if t is filled from a single column, rather than from the columns of a struct.
+func isScalar(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	case reflect.Struct:
+		return !isCompositeStruct(t)
+	}
+	return false
+}
+
+// buildScalarRowMapper handles Querier funcs that return the first column of a query:
+//
+//	T, the value in the first row, or the zero value if there are no rows
+//	*T, the value in the first row, or nil if there are no rows
+//	[]T, the value in every row
+//
+// where T is a number, string, bool, time.Time, or a type that implements sql.Scanner. The query must
+// return a single column. The last result is false if firstResult isn't one of these.
+func buildScalarRowMapper(firstResult reflect.Type) (rowMapper, Mapper, bool) {
+	switch {
+	case isScalar(firstResult):
+		ptrZero := reflect.Zero(reflect.PtrTo(firstResult))
+		return func(rows Rows, mapper Mapper, zeroVal reflect.Value) (reflect.Value, error) {
+			out, err := mapOneRow(rows, mapper, ptrZero)
+			if err != nil || out.IsNil() {
+				return zeroVal, err
+			}
+			return out.Elem(), nil
+		}, scalarMapper(firstResult), true
+	case firstResult.Kind() == reflect.Ptr && isScalar(firstResult.Elem()):
+		return mapOneRow, scalarMapper(firstResult.Elem()), true
+	case firstResult.Kind() == reflect.Slice && isScalar(firstResult.Elem()):
+		returnType := firstResult.Elem()
+		return func(rows Rows, mapper Mapper, zeroVal reflect.Value) (reflect.Value, error) {
+			return mapAllRows(returnType, rows, mapper, zeroVal)
+		}, scalarMapper(returnType), true
+	}
+	return nil, nil, false
+}
+
+// scalarMapper returns a Mapper that stores the only column in a new value of returnType.
+// NULL leaves the value at its zero value.
+func scalarMapper(returnType reflect.Type) Mapper {
+	zeroVal := reflect.Zero(reflect.PtrTo(returnType))
+	sf := &fieldInfo{name: "result", fieldType: returnType, enum: isEnum(returnType)}
+	return func(cols []string, vals []interface{}) (reflect.Value, error) {
+		if len(cols) != 1 {
+			return zeroVal, fmt.Errorf("a query returning %v must have exactly one column, not %d", returnType, len(cols))
+		}
+		out := reflect.New(returnType)
+		if src := *vals[0].(*interface{}); src != nil {
+			if err := assignValue(out.Elem(), src, sf); err != nil {
+				return zeroVal, err
+			}
+		}
+		return out, nil
+	}
+}
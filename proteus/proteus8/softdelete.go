@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// nowParam is the name of the parameter that binds the time of the call, from the clock.
+const nowParam = "proteus_now"
+
+// WithSoftDelete turns on soft deletes, using column to hold the time a row was deleted.
+// An Executor func whose query is a DELETE becomes an UPDATE that sets column to the current time,
+// skipping rows that were already deleted. A Querier func whose query is a SELECT only returns rows
+// where column is NULL; for a join, qualify the column with the alias of the table to check.
+// A func can use a different column with a prosoftdelete tag, or turn soft deletes off with prosoftdelete:"-".
+func WithSoftDelete(column string) Option {
+	return func(o *buildOptions) {
+		o.softDelete = column
+	}
+}
+
+// WithClock sets the func that returns the current time, for soft deletes and audit fields.
+// The default is time.Now; tests can pass a func that returns a fixed time.
+func WithClock(clock func() time.Time) Option {
+	return func(o *buildOptions) {
+		o.clock = clock
+	}
+}
+
+func (o buildOptions) currentTime() time.Time {
+	if o.clock == nil {
+		return time.Now()
+	}
+	return o.clock()
+}
+
+// now is the value of nowParam.
+func (o buildOptions) now(args []reflect.Value) (interface{}, error) {
+	return o.currentTime(), nil
+}
+
+// softDeleteQuery rewrites a DELETE into an UPDATE that sets the soft delete column, if soft deletes are on.
+func softDeleteQuery(query string, opts buildOptions) (string, error) {
+	if opts.softDelete == "" {
+		return query, nil
+	}
+	mask, err := maskQuery(query)
+	if err != nil {
+		return "", err
+	}
+	if statementKind(mask) != "DELETE" {
+		return query, nil
+	}
+	from := findKeyword(mask, "FROM", 0)
+	if from == -1 {
+		return "", fmt.Errorf("can't turn a DELETE without FROM into a soft delete: %s", query)
+	}
+	in := []rune(query)
+	del := findKeyword(mask, "DELETE", 0)
+	query = string(in[:del]) + "UPDATE" + string(in[from+len("FROM"):])
+
+	//SET goes before the WHERE clause, or before RETURNING if there's no WHERE
+	if mask, err = maskQuery(query); err != nil {
+		return "", err
+	}
+	in = []rune(query)
+	pos := findKeyword(mask, "WHERE", 0)
+	if pos == -1 {
+		if pos = findKeyword(mask, "RETURNING", 0); pos == -1 {
+			pos = statementEnd(mask)
+		}
+	}
+	rest := strings.TrimLeftFunc(string(in[pos:]), unicode.IsSpace)
+	query = strings.TrimRightFunc(string(in[:pos]), unicode.IsSpace) + fmt.Sprintf(" SET %s = :%s:", opts.softDelete, nowParam)
+	if rest != "" && rest[0] != ';' {
+		query += " "
+	}
+	query += rest
+	if mask, err = maskQuery(query); err != nil {
+		return "", err
+	}
+	return addCondition(query, mask, opts.softDelete+" IS NULL", "RETURNING"), nil
+}
+
+// selectClauses are the keywords that can end the WHERE clause of a SELECT.
+var selectClauses = []string{"GROUP", "HAVING", "WINDOW", "ORDER", "LIMIT", "OFFSET", "FETCH", "FOR", "UNION", "INTERSECT", "EXCEPT"}
+
+// softSelectQuery makes a SELECT skip soft deleted rows, if soft deletes are on.
+func softSelectQuery(query string, opts buildOptions) (string, error) {
+	if opts.softDelete == "" {
+		return query, nil
+	}
+	mask, err := maskQuery(query)
+	if err != nil {
+		return "", err
+	}
+	if statementKind(mask) != "SELECT" {
+		return query, nil
+	}
+	return addCondition(query, mask, opts.softDelete+" IS NULL", selectClauses...), nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSoftDelete(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	var dao struct {
+		Delete    func(e Executor, id int) (int64, error)              `proq:"DELETE FROM PERSON WHERE id = :id:" prop:"id"`
+		DeleteAll func(e Executor) (int64, error)                      `proq:"DELETE FROM PERSON;"`
+		Purge     func(e Executor, id int) (int64, error)              `proq:"DELETE FROM PERSON WHERE id = :id:" prop:"id" prosoftdelete:"-"`
+		Get       func(q Querier, id int) (*Person, error)             `proq:"SELECT * FROM PERSON WHERE id = :id:" prop:"id"`
+		GetAll    func(q Querier) ([]Person, error)                    `proq:"SELECT * FROM PERSON ORDER BY name"`
+		Joined    func(q Querier, id int) ([]Person, error)            `proq:"SELECT p.* FROM PERSON p JOIN ORDERS o ON o.person_id = p.id WHERE o.id = :id:" prop:"id" prosoftdelete:"p.deleted_at"`
+		Update    func(e Executor, name string, id int) (int64, error) `proq:"UPDATE PERSON SET name = :name: WHERE id = :id:" prop:"name,id"`
+		Returning func(e Executor, id int) (int64, error)              `proq:"DELETE FROM PERSON WHERE id = :id: RETURNING id" prop:"id"`
+	}
+	if err := Build(&dao, Postgres, WithSoftDelete("deleted_at"), WithClock(func() time.Time { return now })); err != nil {
+		t.Fatal(err)
+	}
+	fw := &fakeWrapper{cols: []string{"id", "name", "age"}}
+	dao.Delete(fw, 1)
+	dao.DeleteAll(fw)
+	dao.Purge(fw, 1)
+	dao.Get(fw, 1)
+	dao.GetAll(fw)
+	dao.Joined(fw, 1)
+	dao.Update(fw, "fred", 1)
+	dao.Returning(fw, 1)
+	expectedQueries := []string{
+		"UPDATE PERSON SET deleted_at = $1 WHERE (id = $2) AND deleted_at IS NULL",
+		"UPDATE PERSON SET deleted_at = $1 WHERE deleted_at IS NULL;",
+		"DELETE FROM PERSON WHERE id = $1",
+		"SELECT * FROM PERSON WHERE (id = $1) AND deleted_at IS NULL",
+		"SELECT * FROM PERSON WHERE deleted_at IS NULL ORDER BY name",
+		"SELECT p.* FROM PERSON p JOIN ORDERS o ON o.person_id = p.id WHERE (o.id = $1) AND p.deleted_at IS NULL",
+		"UPDATE PERSON SET name = $1 WHERE id = $2",
+		"UPDATE PERSON SET deleted_at = $1 WHERE (id = $2) AND deleted_at IS NULL RETURNING id",
+	}
+	if !reflect.DeepEqual(fw.queries, expectedQueries) {
+		t.Errorf("expected %q, got %q", expectedQueries, fw.queries)
+	}
+	if !reflect.DeepEqual(fw.args[0], []interface{}{now, 1}) || !reflect.DeepEqual(fw.args[1], []interface{}{now}) {
+		t.Errorf("expected the clock's time to be bound, got %v", fw.args)
+	}
+}
+
+func TestSoftDeleteKeywordParams(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	var dao struct {
+		Limited func(q Querier, limit int) ([]Person, error) `proq:"SELECT * FROM PERSON WHERE age > :limit:" prop:"limit"`
+		Delete  func(e Executor, from int) (int64, error)    `proq:"DELETE FROM PERSON WHERE id = :from:" prop:"from"`
+	}
+	if err := Build(&dao, Postgres, WithSoftDelete("deleted_at"), WithClock(func() time.Time { return now })); err != nil {
+		t.Fatal(err)
+	}
+	fw := &fakeWrapper{cols: []string{"id", "name", "age"}}
+	dao.Limited(fw, 10)
+	dao.Delete(fw, 1)
+	expectedQueries := []string{
+		"SELECT * FROM PERSON WHERE (age > $1) AND deleted_at IS NULL",
+		"UPDATE PERSON SET deleted_at = $1 WHERE (id = $2) AND deleted_at IS NULL",
+	}
+	if !reflect.DeepEqual(fw.queries, expectedQueries) {
+		t.Errorf("expected %q, got %q", expectedQueries, fw.queries)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrStrictMapping is returned in strict mode when the columns of a query result don't line up
+// with the fields of the struct they fill.
+var ErrStrictMapping = errors.New("strict mapping failed")
+
+// WithStrictColumns makes every Querier func that returns structs check that each column in the
+// result has a field to go into, and that each mapped field is filled by a column.
+// The check runs once for each distinct set of columns, and its result is cached.
+func WithStrictColumns() Option {
+	return func(o *buildOptions) {
+		o.strict = true
+	}
+}
+
+type columnChecker struct {
+	structType reflect.Type
+	known      map[string]fieldInfo
+	opts       buildOptions
+	checked    sync.Map
+}
+
+// newColumnChecker builds a checker for structType, including the columns of any many fields.
+func newColumnChecker(structType reflect.Type, opts buildOptions) (*columnChecker, error) {
+	cc := &columnChecker{structType: structType, known: buildColFieldMap(structType, "", opts), opts: opts}
+	plan, err := buildAggregatePlan(structType, opts)
+	if err != nil {
+		return nil, err
+	}
+	if plan != nil {
+		for _, child := range plan.children {
+			for k, v := range child.colFieldMap {
+				cc.known[k] = v
+			}
+		}
+	}
+	return cc, nil
+}
+
+func (cc *columnChecker) check(cols []string) error {
+	key := strings.Join(cols, "\x00")
+	if err, ok := cc.checked.Load(key); ok {
+		if err == nil {
+			return nil
+		}
+		return err.(error)
+	}
+
+	var unmapped, unfilled []string
+	found := map[string]bool{}
+	for _, v := range cols {
+		col := cc.opts.columnKey(v)
+		found[col] = true
+		if _, ok := cc.known[col]; !ok {
+			unmapped = append(unmapped, v)
+		}
+	}
+	for k, v := range cc.known {
+		if !found[k] {
+			unfilled = append(unfilled, v.name)
+		}
+	}
+	sort.Strings(unfilled)
+
+	var err error
+	switch {
+	case len(unmapped) > 0 && len(unfilled) > 0:
+		err = fmt.Errorf("%w for %v: no field for columns %v and no column for fields %v", ErrStrictMapping, cc.structType, unmapped, unfilled)
+	case len(unmapped) > 0:
+		err = fmt.Errorf("%w for %v: no field for columns %v", ErrStrictMapping, cc.structType, unmapped)
+	case len(unfilled) > 0:
+		err = fmt.Errorf("%w for %v: no column for fields %v", ErrStrictMapping, cc.structType, unfilled)
+	}
+	cc.checked.Store(key, err)
+	return err
+}
+
+// strictRowMapper checks the columns before the rows are mapped.
+func strictRowMapper(checker *columnChecker, inner rowMapper) rowMapper {
+	return func(rows Rows, mapper Mapper, zeroVal reflect.Value) (reflect.Value, error) {
+		cols, err := rows.Columns()
+		if err != nil {
+			return zeroVal, err
+		}
+		if err := checker.check(cols); err != nil {
+			return zeroVal, err
+		}
+		return inner(rows, mapper, zeroVal)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStrictColumns(t *testing.T) {
+	var dao struct {
+		Get      func(q Querier, id int) (*Person, error)        `proq:"SELECT * FROM PERSON WHERE id = :id:" prop:"id"`
+		GetAll   func(q Querier) ([]Person, error)               `proq:"SELECT * FROM PERSON"`
+		Report   func(q Querier) (map[string]interface{}, error) `proq:"SELECT * FROM PERSON"`
+		Customer func(q Querier) ([]customer, error)             `proq:"SELECT * FROM PERSON"`
+	}
+	if err := Build(&dao, Postgres, WithStrictColumns()); err != nil {
+		t.Fatal(err)
+	}
+
+	fw := &fakeWrapper{cols: []string{"id", "name", "age"}, rows: [][]interface{}{{int64(1), "Fred", int64(20)}}}
+	if _, err := dao.Get(fw, 1); err != nil {
+		t.Errorf("expected matching columns to pass, got %v", err)
+	}
+
+	fw = &fakeWrapper{cols: []string{"id", "full_name", "age"}, rows: [][]interface{}{{int64(1), "Fred", int64(20)}}}
+	_, err := dao.GetAll(fw)
+	if !errors.Is(err, ErrStrictMapping) || !strings.Contains(err.Error(), "[full_name]") || !strings.Contains(err.Error(), "[Name]") {
+		t.Errorf("expected a strict mapping error naming full_name and Name, got %v", err)
+	}
+	//the second call comes from the cache
+	if _, err2 := dao.GetAll(fw); err2 == nil || err2.Error() != err.Error() {
+		t.Errorf("expected the same error, got %v", err2)
+	}
+
+	if _, err := dao.Report(fw); err != nil {
+		t.Errorf("expected column maps to be exempt, got %v", err)
+	}
+
+	fw = &fakeWrapper{cols: []string{"id", "name", "order_id", "order_total", "tag_name"}}
+	if _, err := dao.Customer(fw); err != nil {
+		t.Errorf("expected many field columns to be known, got %v", err)
+	}
+	fw = &fakeWrapper{cols: []string{"id", "name", "order_id", "tag_name"}}
+	if _, err := dao.Customer(fw); !errors.Is(err, ErrStrictMapping) {
+		t.Errorf("expected a missing many field column to fail, got %v", err)
+	}
+}
+
+func TestColumnCheckerCache(t *testing.T) {
+	checker, err := newColumnChecker(reflect.TypeOf(Person{}), buildOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cols := []string{"id", "name", "age", "extra"}
+	if err := checker.check(cols); err == nil {
+		t.Fatal("expected an error")
+	}
+	count := 0
+	checker.checked.Range(func(k, v interface{}) bool {
+		count++
+		return true
+	})
+	if count != 1 {
+		t.Errorf("expected one cached column set, got %d", count)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+var valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+var timeType = reflect.TypeOf(time.Time{})
+
+// tupleColumns returns the columns that make up a tuple when a slice of sliceElem is expanded, or nil if
+// the elements are scalars. A slice of structs (or pointers to structs) becomes a list of tuples made from
+// the struct's columns, in the order their fields are declared. The columns are found by buildColFieldMap,
+// so embedded structs and the NamingStrategy work as they do when mapping rows.
+// Structs that the driver already knows how to bind, like time.Time, are treated as scalars.
+func tupleColumns(sliceElem reflect.Type, opts buildOptions) ([]structColumn, error) {
+	sliceElem = indirectType(sliceElem)
+	if !isCompositeStruct(sliceElem) {
+		return nil, nil
+	}
+	out := structColumns(sliceElem, opts)
+	if len(out) == 0 {
+		return nil, fmt.Errorf("%v has no fields with a prof tag to make a tuple from", sliceElem)
+	}
+	return out, nil
+}
+
+// tupleTags returns the tags used to encode the values of the tuple columns.
+func tupleTags(cols []structColumn) []profTag {
+	if cols == nil {
+		return nil
+	}
+	out := make([]profTag, len(cols))
+	for k, v := range cols {
+		out[k] = profTag{name: v.name, options: v.info.tag.options}
+	}
+	return out
+}
+
+// tupleFactory builds the template function that writes total tuples of width placeholders each,
+// sharing the placeholder count with join.
+func tupleFactory(join func(int) string) func(int, int) string {
+	return func(width int, total int) string {
+		var b bytes.Buffer
+		if total == 0 {
+			//only reached for EmptySliceFalse; an empty list isn't valid SQL
+			b.WriteString("(")
+			for i := 0; i < width; i++ {
+				if i > 0 {
+					b.WriteString(", ")
+				}
+				b.WriteString("NULL")
+			}
+			b.WriteString(")")
+			return b.String()
+		}
+		for i := 0; i < total; i++ {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString("(")
+			b.WriteString(join(width))
+			b.WriteString(")")
+		}
+		return b.String()
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type tenantUser struct {
+	TenantID int `prof:"tenant_id"`
+	Note     string
+	UserID   int `prof:"user_id"`
+}
+
+type tupleDao struct {
+	GetByKeys  func(q Querier, name string, keys []tenantUser) ([]Person, error) `proq:"SELECT * FROM PERSON WHERE name = :name: AND (tenant_id, user_id) IN (:keys:)" prop:"name,keys"`
+	GetByPtrs  func(q Querier, keys []*tenantUser, age int) ([]Person, error)    `proq:"SELECT * FROM PERSON WHERE (tenant_id, user_id) IN (:keys:) AND age = :age:" prop:"keys,age"`
+	GetByTimes func(q Querier, times []time.Time) ([]Person, error)              `proq:"SELECT * FROM PERSON WHERE created_at IN (:times:)" prop:"times"`
+}
+
+func TestTupleIn(t *testing.T) {
+	data := []struct {
+		name     string
+		pa       ParamAdapter
+		expected string
+	}{
+		{"postgres", Postgres, "SELECT * FROM PERSON WHERE name = $1 AND (tenant_id, user_id) IN (($2, $3), ($4, $5))"},
+		{"mysql", MySQL, "SELECT * FROM PERSON WHERE name = ? AND (tenant_id, user_id) IN ((?, ?), (?, ?))"},
+		{"oracle", Oracle, "SELECT * FROM PERSON WHERE name = :1 AND (tenant_id, user_id) IN ((:2, :3), (:4, :5))"},
+	}
+	for _, v := range data {
+		var dao tupleDao
+		if err := Build(&dao, v.pa); err != nil {
+			t.Fatal(err)
+		}
+		fw := &fakeWrapper{}
+		if _, err := dao.GetByKeys(fw, "Fred", []tenantUser{{1, "a", 10}, {2, "b", 20}}); err != nil {
+			t.Fatal(err)
+		}
+		if fw.queries[0] != v.expected {
+			t.Errorf("%s: expected %q, got %q", v.name, v.expected, fw.queries[0])
+		}
+		if expectedArgs := []interface{}{"Fred", 1, 10, 2, 20}; !reflect.DeepEqual(fw.args[0], expectedArgs) {
+			t.Errorf("%s: expected %v, got %v", v.name, expectedArgs, fw.args[0])
+		}
+	}
+}
+
+func TestTupleInPointers(t *testing.T) {
+	var dao tupleDao
+	if err := Build(&dao, Postgres, WithEmptySlice(EmptySliceFalse)); err != nil {
+		t.Fatal(err)
+	}
+	fw := &fakeWrapper{}
+	if _, err := dao.GetByPtrs(fw, []*tenantUser{{TenantID: 1, UserID: 10}, nil}, 30); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "SELECT * FROM PERSON WHERE (tenant_id, user_id) IN (($1, $2), ($3, $4)) AND age = $5"; fw.queries[0] != expected {
+		t.Errorf("expected %q, got %q", expected, fw.queries[0])
+	}
+	if expectedArgs := []interface{}{1, 10, nil, nil, 30}; !reflect.DeepEqual(fw.args[0], expectedArgs) {
+		t.Errorf("expected %v, got %v", expectedArgs, fw.args[0])
+	}
+
+	if _, err := dao.GetByPtrs(fw, nil, 30); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "SELECT * FROM PERSON WHERE 1=0 AND age = $1"; fw.queries[1] != expected {
+		t.Errorf("expected %q, got %q", expected, fw.queries[1])
+	}
+
+	if _, err := dao.GetByTimes(fw, []time.Time{time.Now(), time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "SELECT * FROM PERSON WHERE created_at IN ($1, $2)"; fw.queries[2] != expected {
+		t.Errorf("expected time.Time to stay a scalar, got %q", fw.queries[2])
+	}
+}
+
+func TestTupleInChunking(t *testing.T) {
+	var dao tupleDao
+	if err := Build(&dao, Postgres, WithMaxParams(5)); err != nil {
+		t.Fatal(err)
+	}
+	fw := &fakeWrapper{}
+	if _, err := dao.GetByKeys(fw, "Fred", []tenantUser{{TenantID: 1}, {TenantID: 2}, {TenantID: 3}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(fw.queries) != 2 || len(fw.args[0]) != 5 || len(fw.args[1]) != 3 {
+		t.Errorf("expected 2 tuples then 1, got %v %v", fw.queries, fw.args)
+	}
+}
+
+type TenantKey struct {
+	TenantID int `prof:"tenant_id"`
+}
+
+type embeddedTenantUser struct {
+	*TenantKey
+	UserID int `prof:"user_id"`
+}
+
+type untaggedKey struct {
+	TenantID int
+	UserID   int
+}
+
+func TestTupleColumns(t *testing.T) {
+	var embedded struct {
+		Get func(q Querier, keys []embeddedTenantUser) ([]Person, error) `proq:"SELECT * FROM PERSON WHERE (tenant_id, user_id) IN (:keys:)" prop:"keys"`
+	}
+	if err := Build(&embedded, Postgres); err != nil {
+		t.Fatal(err)
+	}
+	fw := &fakeWrapper{}
+	if _, err := embedded.Get(fw, []embeddedTenantUser{{&TenantKey{1}, 10}, {nil, 20}}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "SELECT * FROM PERSON WHERE (tenant_id, user_id) IN (($1, $2), ($3, $4))"; fw.queries[0] != expected {
+		t.Errorf("expected %q, got %q", expected, fw.queries[0])
+	}
+	if expectedArgs := []interface{}{1, 10, nil, 20}; !reflect.DeepEqual(fw.args[0], expectedArgs) {
+		t.Errorf("expected %v, got %v", expectedArgs, fw.args[0])
+	}
+
+	var untagged struct {
+		Get func(q Querier, keys []untaggedKey) ([]Person, error) `proq:"SELECT * FROM PERSON WHERE (tenant_id, user_id) IN (:keys:)" prop:"keys"`
+	}
+	if err := Build(&untagged, Postgres); err == nil {
+		t.Error("expected an error for a struct without prof tags")
+	}
+	if err := Build(&untagged, Postgres, WithNamingStrategy(SnakeCase)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := untagged.Get(fw, []untaggedKey{{1, 10}}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "SELECT * FROM PERSON WHERE (tenant_id, user_id) IN (($1, $2))"; fw.queries[1] != expected {
+		t.Errorf("expected %q, got %q", expected, fw.queries[1])
+	}
+	if expectedArgs := []interface{}{1, 10}; !reflect.DeepEqual(fw.args[1], expectedArgs) {
+		t.Errorf("expected %v, got %v", expectedArgs, fw.args[1])
+	}
+}
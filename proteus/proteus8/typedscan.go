@@ -0,0 +1,241 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// typedMapper maps rows into structs by scanning each column straight into its field, instead of
+// scanning into interface{} values and converting them afterwards. The column-to-field plan is worked
+// out once for each distinct set of columns, and the scan destinations are reused for every row.
+type typedMapper struct {
+	returnType  reflect.Type
+	colFieldMap map[string]fieldInfo
+	opts        buildOptions
+	afterLoad   bool
+	plans       sync.Map
+	//lastLen is the number of rows returned by the last call to mapAll, used to size the next result
+	//up to maxSizeHint rows, so one large result doesn't make every later one allocate as much
+	lastLen int64
+}
+
+const maxSizeHint = 256
+
+func newTypedMapper(returnType reflect.Type, opts buildOptions) *typedMapper {
+	return &typedMapper{
+		returnType:  returnType,
+		colFieldMap: buildColFieldMap(returnType, "", opts),
+		opts:        opts,
+		afterLoad:   hasAfterLoad(returnType),
+	}
+}
+
+// scanPlan holds the field for each column, or nil if the column isn't mapped.
+type scanPlan []*fieldInfo
+
+func (tm *typedMapper) plan(cols []string) scanPlan {
+	key := strings.Join(cols, "\x00")
+	if plan, ok := tm.plans.Load(key); ok {
+		return plan.(scanPlan)
+	}
+	plan := make(scanPlan, len(cols))
+	for k, v := range cols {
+		if sf, ok := tm.colFieldMap[tm.opts.columnKey(v)]; ok {
+			sf := sf
+			plan[k] = &sf
+		}
+	}
+	tm.plans.Store(key, plan)
+	return plan
+}
+
+// rowScanner holds the scan destinations for one call; they are pointed at a new struct for each row.
+type rowScanner struct {
+	dests     []interface{}
+	fields    []*fieldScanner
+	afterLoad bool
+}
+
+func (tm *typedMapper) rowScanner(cols []string) *rowScanner {
+	plan := tm.plan(cols)
+	rs := &rowScanner{dests: make([]interface{}, len(plan)), afterLoad: tm.afterLoad}
+	for k, v := range plan {
+		if v == nil {
+			rs.dests[k] = discard
+			continue
+		}
+		fs := &fieldScanner{info: v}
+		rs.fields = append(rs.fields, fs)
+		rs.dests[k] = fs
+	}
+	return rs
+}
+
+func (rs *rowScanner) scan(rows Rows, target reflect.Value) error {
+	for _, v := range rs.fields {
+		v.target = target
+	}
+	if err := rows.Scan(rs.dests...); err != nil {
+		return err
+	}
+	if rs.afterLoad {
+		return runAfterLoad(target)
+	}
+	return nil
+}
+
+func (tm *typedMapper) mapOne(rows Rows, zeroVal reflect.Value) (reflect.Value, error) {
+	if !rows.Next() {
+		return zeroVal, rows.Err()
+	}
+	cols, err := rows.Columns()
+	if err != nil {
+		return zeroVal, err
+	}
+	returnVal := reflect.New(tm.returnType)
+	if err := tm.rowScanner(cols).scan(rows, returnVal.Elem()); err != nil {
+		return zeroVal, err
+	}
+	return returnVal, nil
+}
+
+func (tm *typedMapper) mapAll(rows Rows, zeroVal reflect.Value) (reflect.Value, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return zeroVal, err
+	}
+	rs := tm.rowScanner(cols)
+
+	//an addressable slice can grow and change its length without allocating a new header each time
+	outSlice := reflect.New(reflect.SliceOf(tm.returnType)).Elem()
+	outSlice.Set(reflect.MakeSlice(outSlice.Type(), 0, int(atomic.LoadInt64(&tm.lastLen))))
+	for rows.Next() {
+		n := outSlice.Len()
+		if n == outSlice.Cap() {
+			outSlice.Grow(1)
+		}
+		outSlice.SetLen(n + 1)
+		if err := rs.scan(rows, outSlice.Index(n)); err != nil {
+			return zeroVal, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return zeroVal, err
+	}
+	hint := int64(outSlice.Len())
+	if hint > maxSizeHint {
+		hint = maxSizeHint
+	}
+	atomic.StoreInt64(&tm.lastLen, hint)
+	if outSlice.Len() == 0 {
+		return zeroVal, nil
+	}
+	return outSlice, nil
+}
+
+type discardScanner struct{}
+
+func (discardScanner) Scan(src interface{}) error {
+	return nil
+}
+
+var discard sql.Scanner = discardScanner{}
+
+// fieldScanner is the scan destination for a mapped column. It writes into the field of target.
+type fieldScanner struct {
+	info   *fieldInfo
+	target reflect.Value
+}
+
+func (fs *fieldScanner) Scan(src interface{}) error {
+	if src == nil {
+		//NULL leaves the field at its zero value
+		return nil
+	}
+	return assignValue(fieldByIndexAlloc(fs.target, fs.info.index), src, fs.info)
+}
+
+// assignValue stores a value from the database in a struct field. The common field and driver types are
+// handled without reflection; anything else is converted if the types allow it.
+// Drivers can reuse the memory behind a []byte, so it is always copied.
+// A value that isn't allowed for an enum field is an error.
+func assignValue(field reflect.Value, src interface{}, sf *fieldInfo) error {
+	if err := setValue(field, src, sf); err != nil {
+		return err
+	}
+	if !sf.enum {
+		return nil
+	}
+	return checkEnum(field, sf.name)
+}
+
+func setValue(field reflect.Value, src interface{}, sf *fieldInfo) error {
+	if sf.tag.has("encrypted") {
+		var err error
+		if src, err = decryptValue(src, sf); err != nil {
+			return err
+		}
+	}
+	if sf.tag.has("json") {
+		return decodeJSON(field, src, sf)
+	}
+	switch dest := field.Addr().Interface().(type) {
+	case *string:
+		switch v := src.(type) {
+		case string:
+			*dest = v
+			return nil
+		case []byte:
+			*dest = string(v)
+			return nil
+		}
+	case *int:
+		if v, ok := src.(int64); ok {
+			*dest = int(v)
+			return nil
+		}
+	case *int64:
+		if v, ok := src.(int64); ok {
+			*dest = v
+			return nil
+		}
+	case *float64:
+		if v, ok := src.(float64); ok {
+			*dest = v
+			return nil
+		}
+	case *bool:
+		if v, ok := src.(bool); ok {
+			*dest = v
+			return nil
+		}
+	case *time.Time:
+		if v, ok := src.(time.Time); ok {
+			*dest = v
+			return nil
+		}
+	case *[]byte:
+		if v, ok := src.([]byte); ok {
+			*dest = append([]byte(nil), v...)
+			return nil
+		}
+	case sql.Scanner:
+		return dest.Scan(src)
+	}
+
+	if b, ok := src.([]byte); ok {
+		src = append([]byte(nil), b...)
+	}
+	rv := reflect.ValueOf(src)
+	//Convert would turn an integer into the string holding that rune, not its digits
+	if !rv.Type().ConvertibleTo(sf.fieldType) || (sf.fieldType.Kind() == reflect.String && isIntKind(rv.Kind())) {
+		return fmt.Errorf("Unable to assign value %v of type %v to struct field %s of type %v", rv, rv.Type(), sf.name, sf.fieldType)
+	}
+	field.Set(rv.Convert(sf.fieldType))
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+)
+
+type scanTarget struct {
+	Id      int            `prof:"id"`
+	Score   float64        `prof:"score"`
+	Active  bool           `prof:"active"`
+	Data    []byte         `prof:"data"`
+	Nick    sql.NullString `prof:"nick"`
+	Level   int32          `prof:"level"`
+	Unknown int
+}
+
+func TestTypedScan(t *testing.T) {
+	data := []byte("abc")
+	rows := [][]interface{}{
+		{int64(1), 1.5, true, data, "fred", int64(3), "ignored"},
+		{int64(2), nil, nil, nil, nil, nil, nil},
+	}
+	for i := 0; i < 20; i++ {
+		rows = append(rows, []interface{}{int64(i + 3), nil, nil, nil, nil, nil, nil})
+	}
+	tm := newTypedMapper(reflect.TypeOf(scanTarget{}), buildOptions{})
+	zeroVal := reflect.Zero(reflect.TypeOf([]scanTarget{}))
+	cols := []string{"id", "score", "active", "data", "nick", "level", "other"}
+
+	for pass := 0; pass < 2; pass++ {
+		result, err := tm.mapAll(&fakeRows{cols: cols, rows: rows, pos: -1}, zeroVal)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := result.Interface().([]scanTarget)
+		if len(out) != len(rows) {
+			t.Fatalf("expected %d rows, got %d", len(rows), len(out))
+		}
+		expected := scanTarget{Id: 1, Score: 1.5, Active: true, Data: []byte("abc"), Nick: sql.NullString{String: "fred", Valid: true}, Level: 3}
+		if !reflect.DeepEqual(out[0], expected) {
+			t.Errorf("expected %+v, got %+v", expected, out[0])
+		}
+		for i, v := range out[1:] {
+			if !reflect.DeepEqual(v, scanTarget{Id: i + 2}) {
+				t.Errorf("expected NULLs to leave zero values, got %+v", v)
+			}
+		}
+		data[0] = 'z'
+		if string(out[0].Data) != "abc" {
+			t.Error("expected []byte to be copied")
+		}
+		data[0] = 'a'
+	}
+	if tm.lastLen != int64(len(rows)) {
+		t.Errorf("expected the next result to be sized for %d rows, got %d", len(rows), tm.lastLen)
+	}
+	big := make([][]interface{}, maxSizeHint*4)
+	for i := range big {
+		big[i] = []interface{}{int64(i), nil, nil, nil, nil, nil, nil}
+	}
+	if _, err := tm.mapAll(&fakeRows{cols: cols, rows: big, pos: -1}, zeroVal); err != nil {
+		t.Fatal(err)
+	}
+	result, err := tm.mapAll(&fakeRows{cols: cols, rows: rows[:1], pos: -1}, zeroVal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Cap() > maxSizeHint {
+		t.Errorf("expected the size hint to be capped at %d, got a capacity of %d", maxSizeHint, result.Cap())
+	}
+
+	_, err = tm.mapAll(&fakeRows{cols: []string{"id"}, rows: [][]interface{}{{"not a number"}}, pos: -1}, zeroVal)
+	if err == nil {
+		t.Error("expected an error assigning a string to an int")
+	}
+	pm := newTypedMapper(reflect.TypeOf(Person{}), buildOptions{})
+	_, err = pm.mapAll(&fakeRows{cols: []string{"name"}, rows: [][]interface{}{{int64(65)}}, pos: -1}, reflect.Zero(reflect.TypeOf([]Person{})))
+	if err == nil {
+		t.Error("expected an error assigning an int to a string, rather than a rune conversion")
+	}
+}
+
+func benchmarkRows(n int) ([]string, [][]interface{}) {
+	rows := make([][]interface{}, n)
+	for i := range rows {
+		rows[i] = []interface{}{int64(i), []byte("Fred"), int64(20)}
+	}
+	return []string{"id", "name", "age"}, rows
+}
+
+func BenchmarkMapAllRowsInterface(b *testing.B) {
+	cols, rows := benchmarkRows(100)
+	returnType := reflect.TypeOf(Person{})
+	zeroVal := reflect.Zero(reflect.SliceOf(returnType))
+	mapper := buildMapper(returnType, zeroVal, buildOptions{})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := mapAllRows(returnType, &fakeRows{cols: cols, rows: rows, pos: -1}, mapper, zeroVal); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMapAllRowsTyped(b *testing.B) {
+	cols, rows := benchmarkRows(100)
+	returnType := reflect.TypeOf(Person{})
+	zeroVal := reflect.Zero(reflect.SliceOf(returnType))
+	tm := newTypedMapper(returnType, buildOptions{})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := tm.mapAll(&fakeRows{cols: cols, rows: rows, pos: -1}, zeroVal); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
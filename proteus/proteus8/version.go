@@ -0,0 +1,150 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"unicode"
+)
+
+// ErrStaleVersion is returned by an update func when no row matched the version of the entity passed to it,
+// because the row was changed (or deleted) after the entity was read.
+var ErrStaleVersion = errors.New("stale version")
+
+// oldVersionParam is the name of the parameter that binds the version of the entity passed to an update func.
+const oldVersionParam = "proteus_old_version"
+
+// versionLock describes an Executor func that updates an entity with a version field:
+//
+//	type Person struct {
+//		Id      int    `prof:"id"`
+//		Name    string `prof:"name"`
+//		Version int    `prof:"version,version"`
+//	}
+//
+// The UPDATE is rewritten to increment the version column and to only match the row if its version
+// is still the one in the entity. If no rows are affected, the func returns ErrStaleVersion.
+// If the entity was passed by pointer, its version is incremented after the update succeeds.
+// When the entity is the func's only param, the query's params are bound from its fields:
+//
+//	Update func(e Executor, p *Person) (int64, error) `proq:"UPDATE PERSON SET name = :name: WHERE id = :id:"`
+//
+// The query can't set the version column itself, and the func can't have a proreturn tag.
+type versionLock struct {
+	pos   int
+	index []int
+	col   string
+}
+
+// findVersionLock returns nil if query isn't an UPDATE or funcType has no entity param with a version field.
+func findVersionLock(funcType reflect.Type, query string, opts buildOptions) (*versionLock, error) {
+	var lock *versionLock
+	for i := 1; i < funcType.NumIn(); i++ {
+		t := indirectType(funcType.In(i))
+		if !isCompositeStruct(t) {
+			continue
+		}
+		for col, sf := range buildColFieldMap(t, "", opts) {
+			if !sf.tag.has("version") {
+				continue
+			}
+			if !isIntKind(sf.fieldType.Kind()) {
+				return nil, fmt.Errorf("version field %s of %v must be an integer", sf.name, t)
+			}
+			if lock != nil {
+				return nil, fmt.Errorf("%v has more than one param with a version field", funcType)
+			}
+			lock = &versionLock{pos: i, index: sf.index, col: col}
+		}
+	}
+	if lock == nil {
+		return nil, nil
+	}
+	mask, err := maskQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if statementKind(mask) != "UPDATE" {
+		return nil, nil
+	}
+	if opts.returnCol != "" {
+		return nil, fmt.Errorf("an UPDATE of an entity with a version field can't have a proreturn tag")
+	}
+	if assignsColumn(mask, lock.col) {
+		return nil, fmt.Errorf("the UPDATE can't set the version column %s; it is incremented automatically", lock.col)
+	}
+	return lock, nil
+}
+
+// assignsColumn reports if the SET clause of an UPDATE assigns col.
+func assignsColumn(mask []rune, col string) bool {
+	set := findKeyword(mask, "SET", 0)
+	if set == -1 {
+		return false
+	}
+	end := len(mask)
+	for _, v := range []string{"FROM", "WHERE", "RETURNING"} {
+		if pos := findKeyword(mask, v, set); pos != -1 && pos < end {
+			end = pos
+		}
+	}
+	for pos := findKeyword(mask, col, set); pos != -1 && pos < end; pos = findKeyword(mask, col, pos+1) {
+		next := pos + len([]rune(col))
+		for next < end && unicode.IsSpace(mask[next]) {
+			next++
+		}
+		if next < end && mask[next] == '=' {
+			return true
+		}
+	}
+	return false
+}
+
+// rewrite adds the version check and increment to an UPDATE.
+func (vl *versionLock) rewrite(query string) (string, error) {
+	mask, err := maskQuery(query)
+	if err != nil {
+		return "", err
+	}
+	set := findKeyword(mask, "SET", 0)
+	if set == -1 {
+		return "", fmt.Errorf("can't add a version check to an UPDATE without SET: %s", query)
+	}
+	in := []rune(query)
+	set += len("SET")
+	query = string(in[:set]) + fmt.Sprintf(" %s = %s + 1,", vl.col, vl.col) + string(in[set:])
+	if mask, err = maskQuery(query); err != nil {
+		return "", err
+	}
+	return addCondition(query, mask, fmt.Sprintf("%s = :%s:", vl.col, oldVersionParam), "RETURNING"), nil
+}
+
+func (vl *versionLock) oldVersion(args []reflect.Value) (interface{}, error) {
+	return reflect.Indirect(args[vl.pos]).FieldByIndex(vl.index).Interface(), nil
+}
+
+// checkEntity returns an error if the entity passed to the func is a nil pointer.
+func (vl *versionLock) checkEntity(args []reflect.Value) error {
+	if !reflect.Indirect(args[vl.pos]).IsValid() {
+		return errors.New("can't update a nil entity with a version field")
+	}
+	return nil
+}
+
+// check returns ErrStaleVersion if the update didn't affect any rows. Otherwise, it increments the
+// version of an entity that was passed by pointer.
+func (vl *versionLock) check(args []reflect.Value, result sql.Result) error {
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrStaleVersion
+	}
+	if args[vl.pos].Kind() == reflect.Ptr {
+		field := args[vl.pos].Elem().FieldByIndex(vl.index)
+		field.SetInt(field.Int() + 1)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type versionedPerson struct {
+	Id      int    `prof:"id"`
+	Name    string `prof:"name"`
+	Version int64  `prof:"version,version"`
+}
+
+func TestVersionLock(t *testing.T) {
+	var dao struct {
+		Update    func(e Executor, p *versionedPerson) (int64, error)    `proq:"UPDATE PERSON SET name = :name: WHERE id = :id: OR name = 'WHERE';"`
+		UpdateAll func(e Executor, p versionedPerson, name string) error `proq:"UPDATE PERSON SET name = :name: RETURNING id" prop:"p,name"`
+		Insert    func(e Executor, p versionedPerson, name string) error `proq:"INSERT INTO PERSON(name, version) VALUES(:name:, 1)" prop:"p,name"`
+	}
+	if err := Build(&dao, Postgres); err != nil {
+		t.Fatal(err)
+	}
+	fw := &fakeWrapper{result: fakeResult{rowsAffected: 1}}
+	p := &versionedPerson{Id: 1, Name: "bob", Version: 3}
+	if _, err := dao.Update(fw, p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Version != 4 {
+		t.Errorf("expected the version to be incremented, got %d", p.Version)
+	}
+	if err := dao.UpdateAll(fw, *p, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dao.Insert(fw, *p, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	expectedQueries := []string{
+		"UPDATE PERSON SET version = version + 1, name = $1 WHERE (id = $2 OR name = 'WHERE') AND version = $3;",
+		"UPDATE PERSON SET version = version + 1, name = $1 WHERE version = $2 RETURNING id",
+		"INSERT INTO PERSON(name, version) VALUES($1, 1)",
+	}
+	expectedArgs := [][]interface{}{{"bob", 1, int64(3)}, {"bob", int64(4)}, {"bob"}}
+	if !reflect.DeepEqual(fw.queries, expectedQueries) {
+		t.Errorf("expected %q, got %q", expectedQueries, fw.queries)
+	}
+	if !reflect.DeepEqual(fw.args, expectedArgs) {
+		t.Errorf("expected %#v, got %#v", expectedArgs, fw.args)
+	}
+
+	fw.result.rowsAffected = 0
+	if _, err := dao.Update(fw, p); !errors.Is(err, ErrStaleVersion) {
+		t.Errorf("expected ErrStaleVersion, got %v", err)
+	}
+	if p.Version != 4 {
+		t.Errorf("expected the version to be unchanged, got %d", p.Version)
+	}
+	if _, err := dao.Update(fw, nil); err == nil {
+		t.Error("expected an error for a nil entity")
+	}
+}
+
+func TestVersionLockErrors(t *testing.T) {
+	type badVersion struct {
+		Version string `prof:"version,version"`
+	}
+	var dao struct {
+		Update func(e Executor, p badVersion) error `proq:"UPDATE PERSON SET name = 'x'" prop:"p"`
+	}
+	if err := Build(&dao, Postgres); err == nil {
+		t.Error("expected an error for a version field that isn't an integer")
+	}
+	var dao2 struct {
+		Update func(e Executor, p, p2 versionedPerson) error `proq:"UPDATE PERSON SET name = 'x'" prop:"p,p2"`
+	}
+	if err := Build(&dao2, Postgres); err == nil {
+		t.Error("expected an error for two versioned params")
+	}
+	var dao3 struct {
+		Update func(e Executor, p versionedPerson) error `proq:"UPDATE PERSON SET name = :name:, version = :version: + 1 WHERE id = :id:"`
+	}
+	if err := Build(&dao3, Postgres); err == nil {
+		t.Error("expected an error for an UPDATE that sets the version column")
+	}
+	var dao4 struct {
+		Update func(e Executor, p versionedPerson) (int64, error) `proq:"UPDATE PERSON SET name = :name: WHERE id = :id:" proreturn:"id"`
+	}
+	if err := Build(&dao4, Postgres, WithReturning()); err == nil {
+		t.Error("expected an error for an UPDATE with a version field and a proreturn tag")
+	}
+}
+
+func TestVersionLockKeywordParams(t *testing.T) {
+	var dao struct {
+		Update func(e Executor, p *versionedPerson, where string, set int) (int64, error) `proq:"UPDATE PERSON SET name = :where: WHERE id = :set:" prop:"p,where,set"`
+	}
+	if err := Build(&dao, Postgres); err != nil {
+		t.Fatal(err)
+	}
+	fw := &fakeWrapper{result: fakeResult{rowsAffected: 1}}
+	if _, err := dao.Update(fw, &versionedPerson{Version: 2}, "bob", 1); err != nil {
+		t.Fatal(err)
+	}
+	expected := "UPDATE PERSON SET version = version + 1, name = $1 WHERE (id = $2) AND version = $3"
+	if fw.queries[0] != expected {
+		t.Errorf("expected %q, got %q", expected, fw.queries[0])
+	}
+}